@@ -0,0 +1,233 @@
+// Copyright 2018 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package env
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// Option configures the behavior of a Decoder or an Encoder. Options that
+// only make sense for one of them are ignored by the other.
+type Option func(*config)
+
+// config holds the settings shared by everything an Option can tune.
+type config struct {
+	// allErrors makes Unmarshal walk the whole struct and report every
+	// failing field instead of stopping at the first one
+	allErrors bool
+	// registry holds the parse and format functions of custom types
+	registry *Registry
+	// tag is the name of the struct field tag holding the keys and options,
+	// "env" if empty
+	tag string
+	// separator is the separator of slice fields without a separator option,
+	// defaultSliceSeparator if empty
+	separator string
+	// strictTags makes unknown tag options an error instead of ignoring them
+	strictTags bool
+	// fileFallback makes Unmarshal read the value of missing keys from the
+	// file named by the same key followed by "_FILE"
+	fileFallback bool
+	// fsys is the file system files are read from, the OS one if nil
+	fsys fs.FS
+	// expand makes Unmarshal expand references to other variables in values
+	// and defaults
+	expand bool
+	// ownedPrefixes are the prefixes of the keys that must all be read by a
+	// field
+	ownedPrefixes []string
+	// deleteAliases makes Unmarshal delete every key of the fields it sets
+	// from the EnvSet, instead of the matched one only
+	deleteAliases bool
+	// keepEnvSet makes Unmarshal leave the EnvSet untouched
+	keepEnvSet bool
+	// report is filled with the source of the value of every field, if not
+	// nil
+	report *Report
+	// redact makes Marshal write "[REDACTED]" instead of the value of secret
+	// fields
+	redact bool
+}
+
+// tagName returns the name of the struct field tag holding the keys and
+// options.
+func (c *config) tagName() string {
+	if c.tag == "" {
+		return "env"
+	}
+	return c.tag
+}
+
+// prefixTagName returns the name of the struct field tag holding the prefix of
+// nested structs, which is the tag name followed by "Prefix".
+func (c *config) prefixTagName() string {
+	return c.tagName() + "Prefix"
+}
+
+// sliceSeparator returns the separator of slice fields with the given tag.
+func (c *config) sliceSeparator(t tag) string {
+	switch {
+	case t.Separator != "":
+		return t.Separator
+	case c.separator != "":
+		return c.separator
+	default:
+		return defaultSliceSeparator
+	}
+}
+
+// checkTag returns an error wrapping ErrInvalidTag if t cannot be used.
+func (c *config) checkTag(t tag) error {
+	if c.strictTags && len(t.Unknown) > 0 {
+		return fmt.Errorf("%w: unknown option %q", ErrInvalidTag, t.Unknown[0])
+	}
+	return nil
+}
+
+// WithAllErrors makes the Decoder visit every field even after one of them
+// fails, and return all failures at once as an Errors value.
+func WithAllErrors() Option {
+	return func(c *config) {
+		c.allErrors = true
+	}
+}
+
+// WithRegistry makes the Decoder and Encoder use the parse and format
+// functions registered in r. They take precedence over every other way of
+// handling the registered types.
+func WithRegistry(r *Registry) Option {
+	return func(c *config) {
+		c.registry = r
+	}
+}
+
+// WithTagName makes the Decoder and Encoder read the keys and options of
+// fields from the struct field tag with the given name instead of "env". The
+// prefix of nested structs is then read from the tag name followed by
+// "Prefix", such as "configPrefix" for "config".
+func WithTagName(name string) Option {
+	return func(c *config) {
+		c.tag = name
+	}
+}
+
+// WithSeparator makes the Decoder and Encoder split and join slice fields
+// without a separator tag option on sep instead of "|".
+func WithSeparator(sep string) Option {
+	return func(c *config) {
+		c.separator = sep
+	}
+}
+
+// WithStrictTags makes the Decoder and Encoder return an error wrapping
+// ErrInvalidTag for fields with unknown tag options, which are ignored
+// otherwise.
+func WithStrictTags() Option {
+	return func(c *config) {
+		c.strictTags = true
+	}
+}
+
+// WithDeleteAliases makes the Decoder delete every key of the fields it sets
+// from the EnvSet, and their "_FILE" variants with WithFileFallback, instead of
+// the key the value was read from only. For example, a field tagged
+// `env:"PORT,HTTP_PORT"` set from PORT also deletes HTTP_PORT.
+func WithDeleteAliases() Option {
+	return func(c *config) {
+		c.deleteAliases = true
+	}
+}
+
+// WithKeepEnvSet makes the Decoder leave the EnvSet untouched, instead of
+// deleting the variables it matches, so the same EnvSet can be unmarshalled
+// into several structs.
+func WithKeepEnvSet() Option {
+	return func(c *config) {
+		c.keepEnvSet = true
+	}
+}
+
+// Decoder unmarshals EnvSets into structs. The zero value behaves like the
+// package level Unmarshal function.
+type Decoder struct {
+	config config
+}
+
+// NewDecoder returns a Decoder configured with opts.
+func NewDecoder(opts ...Option) *Decoder {
+	d := &Decoder{}
+	for _, opt := range opts {
+		opt(&d.config)
+	}
+	return d
+}
+
+// Unmarshal behaves like the package level Unmarshal function, using the
+// options of d.
+func (d *Decoder) Unmarshal(es EnvSet, v interface{}) error {
+	return unmarshal(&d.config, es, v)
+}
+
+// UnmarshalFromEnviron behaves like the package level UnmarshalFromEnviron
+// function, using the options of d.
+func (d *Decoder) UnmarshalFromEnviron(v interface{}) (EnvSet, error) {
+	es, err := EnvironToEnvSet(os.Environ())
+	if err != nil {
+		return nil, err
+	}
+
+	return es, d.Unmarshal(es, v)
+}
+
+// Encoder marshals structs into EnvSets. The zero value behaves like the
+// package level Marshal function.
+type Encoder struct {
+	config config
+}
+
+// NewEncoder returns an Encoder configured with opts.
+func NewEncoder(opts ...Option) *Encoder {
+	e := &Encoder{}
+	for _, opt := range opts {
+		opt(&e.config)
+	}
+	return e
+}
+
+// Marshal behaves like the package level Marshal function, using the options
+// of e.
+func (e *Encoder) Marshal(v interface{}) (EnvSet, error) {
+	return marshal(&e.config, v)
+}
+
+// Errors is returned by a Decoder created with WithAllErrors when one or more
+// fields fail to unmarshal. The errors are ordered the way the fields are
+// declared, and each of them can be matched with errors.Is and errors.As.
+type Errors []error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the underlying errors for use by errors.Is and errors.As.
+func (e Errors) Unwrap() []error {
+	return e
+}
@@ -0,0 +1,236 @@
+// Copyright 2018 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package env
+
+import (
+	"errors"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+type AllErrorsStruct struct {
+	Int      int    `env:"INT"`
+	Required string `env:"REQUIRED,required=true"`
+	Nested   struct {
+		Bool bool `env:"BOOL"`
+	}
+	Unsupported complex128 `env:"UNSUPPORTED"`
+	Valid       string     `env:"VALID"`
+}
+
+func TestDecoderAllErrors(t *testing.T) {
+	t.Parallel()
+	var (
+		environ = map[string]string{
+			"INT":         "abc",
+			"BOOL":        "maybe",
+			"UNSUPPORTED": "1+2i",
+			"VALID":       "valid",
+		}
+		allErrorsStruct AllErrorsStruct
+	)
+
+	err := NewDecoder(WithAllErrors()).Unmarshal(environ, &allErrorsStruct)
+
+	var errs Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected error 'Errors' but got '%v'", err)
+	}
+
+	if len(errs) != 4 {
+		t.Fatalf("Expected %d errors but got %d: '%s'", 4, len(errs), err)
+	}
+
+	var numErr *strconv.NumError
+	if !errors.As(errs[0], &numErr) || numErr.Func != "Atoi" {
+		t.Errorf("Expected first error to come from 'Atoi' but got '%s'", errs[0])
+	}
+
+	var missing *ErrMissingRequiredValue
+	if !errors.As(errs[1], &missing) || missing.Value != "REQUIRED" {
+		t.Errorf("Expected second error 'ErrMissingRequiredValue' but got '%s'", errs[1])
+	}
+
+	if !errors.As(errs[2], &numErr) || numErr.Func != "ParseBool" {
+		t.Errorf("Expected third error to come from 'ParseBool' but got '%s'", errs[2])
+	}
+
+	if !errors.Is(errs[3], ErrUnsupportedType) {
+		t.Errorf("Expected fourth error 'ErrUnsupportedType' but got '%s'", errs[3])
+	}
+
+	if !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("Expected error to match 'ErrUnsupportedType' but got '%s'", err)
+	}
+
+	if allErrorsStruct.Valid != "valid" {
+		t.Errorf("Expected field value to be '%s' but got '%s'", "valid", allErrorsStruct.Valid)
+	}
+}
+
+func TestDecoderStopsAtFirstError(t *testing.T) {
+	t.Parallel()
+	var (
+		environ         = map[string]string{"INT": "abc", "VALID": "valid"}
+		allErrorsStruct AllErrorsStruct
+	)
+
+	err := NewDecoder().Unmarshal(environ, &allErrorsStruct)
+
+	var errs Errors
+	if errors.As(err, &errs) {
+		t.Errorf("Expected a single error but got '%s'", err)
+	}
+
+	var numErr *strconv.NumError
+	if !errors.As(err, &numErr) {
+		t.Errorf("Expected error '*strconv.NumError' but got '%v'", err)
+	}
+
+	if allErrorsStruct.Valid != "" {
+		t.Errorf("Expected field value to be '%s' but got '%s'", "", allErrorsStruct.Valid)
+	}
+}
+
+type TagNameStruct struct {
+	Home     string   `config:"HOME" env:"IGNORED"`
+	Values   []string `config:"VALUES"`
+	Database struct {
+		Host string `config:"HOST"`
+	} `configPrefix:"DB_"`
+}
+
+func TestDecoderOptions(t *testing.T) {
+	t.Parallel()
+	var (
+		environ = map[string]string{
+			"HOME":    "/home/test",
+			"IGNORED": "ignored",
+			"VALUES":  "a,b|c",
+			"DB_HOST": "localhost",
+		}
+		tagNameStruct TagNameStruct
+	)
+
+	decoder := NewDecoder(WithTagName("config"), WithSeparator(","))
+	if err := decoder.Unmarshal(environ, &tagNameStruct); err != nil {
+		t.Errorf("Expected no error but got '%s'", err)
+	}
+
+	testCases := [][]interface{}{
+		{tagNameStruct.Home, "/home/test"},
+		{tagNameStruct.Values, []string{"a", "b|c"}},
+		{tagNameStruct.Database.Host, "localhost"},
+	}
+	for _, testCase := range testCases {
+		if !reflect.DeepEqual(testCase[0], testCase[1]) {
+			t.Errorf("Expected field value to be '%v' but got '%v'", testCase[1], testCase[0])
+		}
+	}
+}
+
+func TestEncoderOptions(t *testing.T) {
+	t.Parallel()
+	var tagNameStruct TagNameStruct
+	tagNameStruct.Home = "/home/test"
+	tagNameStruct.Values = []string{"a", "b"}
+	tagNameStruct.Database.Host = "localhost"
+
+	es, err := NewEncoder(WithTagName("config")).Marshal(&tagNameStruct)
+	if err != nil {
+		t.Errorf("Expected no error but got '%s'", err)
+	}
+
+	for k, v := range map[string]string{"HOME": "/home/test", "DB_HOST": "localhost"} {
+		if es[k] != v {
+			t.Errorf("Expected field value to be '%s' but got '%s'", v, es[k])
+		}
+	}
+
+	if v, ok := es["IGNORED"]; ok {
+		t.Errorf("Expected field '%s' to not exist but got '%s'", "IGNORED", v)
+	}
+}
+
+func TestStrictTags(t *testing.T) {
+	t.Parallel()
+	var (
+		environ              = map[string]string{"REQUIRED_VAL": "a", "REQUIRED_VAL_MORE": "b"}
+		requiredValuesStruct RequiredValueStruct
+	)
+
+	if err := NewDecoder(WithKeepEnvSet()).Unmarshal(environ, &requiredValuesStruct); err != nil {
+		t.Errorf("Expected no error but got '%s'", err)
+	}
+
+	err := NewDecoder(WithStrictTags()).Unmarshal(environ, &requiredValuesStruct)
+	var fieldErr *FieldError
+	if !errors.Is(err, ErrInvalidTag) || !errors.As(err, &fieldErr) {
+		t.Errorf("Expected error 'ErrInvalidTag' but got '%v'", err)
+	} else if fieldErr.Field != "InvalidExtra" {
+		t.Errorf("Expected field path to be '%s' but got '%s'", "InvalidExtra", fieldErr.Field)
+	}
+
+	if _, err := NewEncoder(WithStrictTags()).Marshal(&requiredValuesStruct); !errors.Is(err, ErrInvalidTag) {
+		t.Errorf("Expected error 'ErrInvalidTag' but got '%v'", err)
+	}
+}
+
+type AliasStruct struct {
+	Port     int    `env:"PORT,HTTP_PORT"`
+	Required string `env:"REQUIRED,required=true"`
+	Secret   string `env:"SECRET"`
+}
+
+func TestDecoderConsumption(t *testing.T) {
+	t.Parallel()
+	newEnviron := func() EnvSet {
+		return EnvSet{
+			"PORT":        "80",
+			"HTTP_PORT":   "8080",
+			"REQUIRED":    "required",
+			"SECRET_FILE": "/run/secrets/secret",
+			"EXTRA":       "extra",
+		}
+	}
+
+	testCases := []struct {
+		opts      []Option
+		remaining []string
+	}{
+		{nil, []string{"EXTRA", "HTTP_PORT", "SECRET_FILE"}},
+		{[]Option{WithDeleteAliases()}, []string{"EXTRA", "SECRET_FILE"}},
+		{[]Option{WithKeepEnvSet()}, []string{"EXTRA", "HTTP_PORT", "PORT", "REQUIRED", "SECRET_FILE"}},
+	}
+	for _, testCase := range testCases {
+		var (
+			environ     = newEnviron()
+			aliasStruct AliasStruct
+		)
+		if err := NewDecoder(testCase.opts...).Unmarshal(environ, &aliasStruct); err != nil {
+			t.Errorf("Expected no error but got '%s'", err)
+		}
+		if aliasStruct.Port != 80 {
+			t.Errorf("Expected field value to be '%d' but got '%d'", 80, aliasStruct.Port)
+		}
+
+		remaining := slices.Sorted(maps.Keys(environ))
+		if !reflect.DeepEqual(remaining, testCase.remaining) {
+			t.Errorf("Expected remaining keys to be '%v' but got '%v'", testCase.remaining, remaining)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+// Copyright 2018 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package env
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrInvalidDotenv returned when a dotenv file has an incorrect format.
+var ErrInvalidDotenv = errors.New("invalid dotenv file")
+
+// ParseDotenv reads a dotenv file from r and returns the variables it
+// defines. Each line holds a KEY=value assignment, optionally preceded by
+// "export ", while blank lines and lines starting with "#" are ignored.
+//
+// Values follow the shell quoting rules, and are made of segments that are
+// concatenated:
+//
+//   - single quoted segments are taken literally,
+//   - double quoted segments interpret the \n, \r, \t, \", \\ and \$ escapes,
+//     and keep other backslashes,
+//   - unquoted segments end at a "#" preceded by whitespace, which starts a
+//     comment, and a backslash includes the next character literally, or
+//     continues the value on the next line when it ends the line.
+//
+// Quoted segments can span several lines. Whitespace around unquoted segments
+// is trimmed. When a key is assigned several times, the last value wins.
+//
+// If the file is malformed, ParseDotenv returns an error wrapping
+// ErrInvalidDotenv and giving the line of the problem.
+func ParseDotenv(r io.Reader) (EnvSet, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &dotenvParser{src: string(b), line: 1}
+	es := make(EnvSet)
+	for {
+		key, value, ok, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return es, nil
+		}
+		es[key] = value
+	}
+}
+
+// LoadDotenvFiles parses the dotenv files at paths with ParseDotenv, and
+// merges their variables in a single EnvSet. Files are layered in order, so
+// the variables of a file override the ones of the files before it, such as
+// in LoadDotenvFiles(".env", ".env.local").
+func LoadDotenvFiles(paths ...string) (EnvSet, error) {
+	es := make(EnvSet)
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		fileEnvSet, err := ParseDotenv(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for k, v := range fileEnvSet {
+			es[k] = v
+		}
+	}
+	return es, nil
+}
+
+// dotenvParser holds the state of a single call to ParseDotenv.
+type dotenvParser struct {
+	src string
+	pos int
+	// line is the line of src at pos, starting at 1
+	line int
+}
+
+// errorf returns an error wrapping ErrInvalidDotenv at the given line.
+func (p *dotenvParser) errorf(line int, format string, args ...interface{}) error {
+	return fmt.Errorf("%w: line %d: %s", ErrInvalidDotenv, line, fmt.Sprintf(format, args...))
+}
+
+// peek returns the byte at pos, or 0 at the end of src.
+func (p *dotenvParser) peek() byte {
+	if p.pos >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+// advance moves pos to the next byte, counting lines.
+func (p *dotenvParser) advance() {
+	if p.src[p.pos] == '\n' {
+		p.line++
+	}
+	p.pos++
+}
+
+// atLineEnd reports whether pos is at the end of a line, which ends with
+// "\n" or "\r\n", or at the end of src.
+func (p *dotenvParser) atLineEnd() bool {
+	rest := p.src[p.pos:]
+	return rest == "" || rest[0] == '\n' || strings.HasPrefix(rest, "\r\n")
+}
+
+// skipSpaces skips spaces and tabs, and reports whether there were any.
+func (p *dotenvParser) skipSpaces() bool {
+	start := p.pos
+	for c := p.peek(); c == ' ' || c == '\t'; c = p.peek() {
+		p.advance()
+	}
+	return p.pos > start
+}
+
+// skipLine skips the rest of the current line, including its newline.
+func (p *dotenvParser) skipLine() {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		p.advance()
+		if c == '\n' {
+			return
+		}
+	}
+}
+
+// next parses the next assignment. It returns ok false at the end of src.
+func (p *dotenvParser) next() (key, value string, ok bool, err error) {
+	for {
+		p.skipSpaces()
+		if p.pos >= len(p.src) {
+			return "", "", false, nil
+		}
+		if p.atLineEnd() || p.peek() == '#' {
+			p.skipLine()
+			continue
+		}
+		break
+	}
+
+	line := p.line
+	key = p.readKey()
+	if key == "export" && p.skipSpaces() && p.peek() != '=' {
+		key = p.readKey()
+	}
+	if key == "" {
+		return "", "", false, p.errorf(line, "expected a key")
+	}
+	p.skipSpaces()
+	if p.peek() != '=' {
+		return "", "", false, p.errorf(line, "expected \"=\" after key %q", key)
+	}
+	p.advance()
+
+	value, err = p.readValue()
+	if err != nil {
+		return "", "", false, err
+	}
+	return key, value, true, nil
+}
+
+// readKey reads a key made of letters, digits and underscores, which does not
+// start with a digit.
+func (p *dotenvParser) readKey() string {
+	start := p.pos
+	for p.pos < len(p.src) && isVariableChar(p.src[p.pos], p.pos == start) {
+		p.advance()
+	}
+	return p.src[start:p.pos]
+}
+
+// readValue reads the value of an assignment and the rest of its line.
+func (p *dotenvParser) readValue() (string, error) {
+	var b strings.Builder
+	if p.skipSpaces() && p.peek() == '#' {
+		p.skipLine()
+		return "", nil
+	}
+
+	for !p.atLineEnd() {
+		switch c := p.peek(); c {
+		case ' ', '\t':
+			start := p.pos
+			p.skipSpaces()
+			if p.atLineEnd() || p.peek() == '#' {
+				p.skipLine()
+				return b.String(), nil
+			}
+			b.WriteString(p.src[start:p.pos])
+		case '\'':
+			line := p.line
+			p.advance()
+			end := strings.IndexByte(p.src[p.pos:], '\'')
+			if end < 0 {
+				return "", p.errorf(line, "unterminated single quote")
+			}
+			for range end {
+				b.WriteByte(p.peek())
+				p.advance()
+			}
+			p.advance()
+		case '"':
+			if err := p.readDoubleQuoted(&b); err != nil {
+				return "", err
+			}
+		case '\\':
+			p.advance()
+			if p.pos < len(p.src) && p.atLineEnd() {
+				// a backslash-newline continues the value on the next line
+				if p.peek() == '\r' {
+					p.advance()
+				}
+				p.advance()
+			} else if c := p.peek(); c != 0 {
+				b.WriteByte(c)
+				p.advance()
+			} else {
+				b.WriteByte('\\')
+			}
+		default:
+			b.WriteByte(c)
+			p.advance()
+		}
+	}
+	p.skipLine()
+	return b.String(), nil
+}
+
+// readDoubleQuoted reads a double quoted segment into b.
+func (p *dotenvParser) readDoubleQuoted(b *strings.Builder) error {
+	line := p.line
+	p.advance()
+	for p.pos < len(p.src) {
+		c := p.peek()
+		p.advance()
+		switch c {
+		case '"':
+			return nil
+		case '\\':
+			switch e := p.peek(); e {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case '"', '\\', '$':
+				b.WriteByte(e)
+			default:
+				b.WriteByte('\\')
+				continue
+			}
+			p.advance()
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return p.errorf(line, "unterminated double quote")
+}
@@ -0,0 +1,141 @@
+// Copyright 2018 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package env
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDotenv(t *testing.T) {
+	t.Parallel()
+	dotenv := `# comment
+PLAIN=value
+export EXPORTED=exported
+  SPACED  =  spaced value  # trailing comment
+EMPTY=
+EMPTY_COMMENT= # only a comment
+HASH=a#b
+SINGLE='it''s $HOME \n'
+DOUBLE="say \"hi\"\t\\ \$HOME \q"
+MULTI="first
+second"
+MULTI_SINGLE='first
+  second'
+ESCAPED=a\ b\#c
+CONCAT="a"'b'c
+CONTINUED=abc\
+def
+CRLF_CONT=abc\` + "\r\n" + `def
+NEXT=1
+export=1
+CRLF=crlf` + "\r\n" + `LAST=last`
+
+	es, err := ParseDotenv(strings.NewReader(dotenv))
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+
+	expected := EnvSet{
+		"PLAIN":         "value",
+		"EXPORTED":      "exported",
+		"SPACED":        "spaced value",
+		"EMPTY":         "",
+		"EMPTY_COMMENT": "",
+		"HASH":          "a#b",
+		"SINGLE":        `its $HOME \n`,
+		"DOUBLE":        "say \"hi\"\t\\ $HOME \\q",
+		"MULTI":         "first\nsecond",
+		"MULTI_SINGLE":  "first\n  second",
+		"ESCAPED":       "a b#c",
+		"CONCAT":        "abc",
+		"CONTINUED":     "abcdef",
+		"CRLF_CONT":     "abcdef",
+		"NEXT":          "1",
+		"export":        "1",
+		"CRLF":          "crlf",
+		"LAST":          "last",
+	}
+	if !reflect.DeepEqual(es, expected) {
+		for k, v := range expected {
+			if es[k] != v {
+				t.Errorf("Expected value of '%s' to be '%q' but got '%q'", k, v, es[k])
+			}
+		}
+		if len(es) != len(expected) {
+			t.Errorf("Expected %d variables but got %d", len(expected), len(es))
+		}
+	}
+}
+
+func TestParseDotenvErrors(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		dotenv string
+		line   string
+	}{
+		{"A=1\nB", "line 2: expected \"=\""},
+		{"A=1\n\n=2", "line 3: expected a key"},
+		{"A=1\nB='open\nC=3", "line 2: unterminated single quote"},
+		{"A=\"a\nb\"\nB=\"open", "line 3: unterminated double quote"},
+		{"1A=1", "line 1: expected a key"},
+		{"A=a\\\nb\nB", "line 3: expected \"=\""},
+	}
+	for _, testCase := range testCases {
+		_, err := ParseDotenv(strings.NewReader(testCase.dotenv))
+		if !errors.Is(err, ErrInvalidDotenv) {
+			t.Errorf("Expected error 'ErrInvalidDotenv' but got '%v'", err)
+		} else if !strings.Contains(err.Error(), testCase.line) {
+			t.Errorf("Expected error to contain '%s' but got '%s'", testCase.line, err)
+		}
+	}
+}
+
+func TestLoadDotenvFiles(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	base := filepath.Join(dir, ".env")
+	local := filepath.Join(dir, ".env.local")
+	invalid := filepath.Join(dir, ".env.invalid")
+	for path, content := range map[string]string{
+		base:    "HOST=localhost\nPORT=8080\n",
+		local:   "PORT=9090\nDEBUG=true\n",
+		invalid: "A=1\nB\n",
+	} {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("Expected no error but got '%s'", err)
+		}
+	}
+
+	es, err := LoadDotenvFiles(base, local)
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+	expected := EnvSet{"HOST": "localhost", "PORT": "9090", "DEBUG": "true"}
+	if !reflect.DeepEqual(es, expected) {
+		t.Errorf("Expected EnvSet to be '%v' but got '%v'", expected, es)
+	}
+
+	if _, err := LoadDotenvFiles(base, invalid); !errors.Is(err, ErrInvalidDotenv) || !strings.Contains(err.Error(), invalid) {
+		t.Errorf("Expected error 'ErrInvalidDotenv' naming the file but got '%v'", err)
+	}
+
+	if _, err := LoadDotenvFiles(filepath.Join(dir, "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected error 'os.ErrNotExist' but got '%v'", err)
+	}
+}
@@ -17,10 +17,12 @@
 package env
 
 import (
+	"encoding"
 	"errors"
 	"fmt"
-	"os"
+	"maps"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -35,6 +37,45 @@ const (
 	// tagKeySeparator is the key used in the struct field tag to specify a
 	// separator for slice fields
 	tagKeySeparator = "separator"
+	// tagKeyKVSeparator is the key used in the struct field tag to specify a
+	// separator between the key and the value of map entries
+	tagKeyKVSeparator = "kvseparator"
+	// tagKeyFile is the key used in the struct field tag to specify that the
+	// value of the field is the path of a file holding the actual value
+	tagKeyFile = "file"
+	// tagKeyMin is the key used in the struct field tag to specify the minimum
+	// value, or length, of the field
+	tagKeyMin = "min"
+	// tagKeyMax is the key used in the struct field tag to specify the maximum
+	// value, or length, of the field
+	tagKeyMax = "max"
+	// tagKeyOneOf is the key used in the struct field tag to specify the
+	// allowed values of the field, separated by "|"
+	tagKeyOneOf = "oneof"
+	// tagKeyPattern is the key used in the struct field tag to specify a
+	// regular expression the value of the field must match
+	tagKeyPattern = "pattern"
+	// tagKeyNotEmpty is the key used in the struct field tag to specify that
+	// the value of the field must not be empty
+	tagKeyNotEmpty = "notempty"
+	// tagKeyExclusive is the key used in the struct field tag to specify a
+	// group of fields of which at most one can be set
+	tagKeyExclusive = "exclusive"
+	// tagKeyTogether is the key used in the struct field tag to specify a
+	// group of fields that must be set together or not at all
+	tagKeyTogether = "together"
+	// tagKeySecret is the key used in the struct field tag to specify that the
+	// value of the field is a secret
+	tagKeySecret = "secret"
+
+	// defaultSliceSeparator is used to split slice fields without a separator
+	defaultSliceSeparator = "|"
+	// defaultMapSeparator is used to split the entries of map fields without a
+	// separator
+	defaultMapSeparator = ","
+	// defaultKVSeparator is used to split the key from the value of map entries
+	// of fields without a kvseparator
+	defaultKVSeparator = ":"
 )
 
 var (
@@ -48,8 +89,26 @@ var (
 	// ErrUnexportedField returned when a field with tag "env" is not exported.
 	ErrUnexportedField = errors.New("field must be exported")
 
+	// ErrInvalidTag returned when a field tag cannot be used, because it is
+	// malformed or, with WithStrictTags, has unknown options.
+	ErrInvalidTag = errors.New("invalid field tag")
+
+	// stringType is the reflect.Type of string
+	stringType = reflect.TypeOf("")
+
 	// unmarshalType is the reflect.Type element of the Unmarshaler interface
 	unmarshalType = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+
+	// textUnmarshalType is the reflect.Type element of the
+	// encoding.TextUnmarshaler interface
+	textUnmarshalType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+	// marshalType is the reflect.Type element of the Marshaler interface
+	marshalType = reflect.TypeOf((*Marshaler)(nil)).Elem()
+
+	// textMarshalType is the reflect.Type element of the
+	// encoding.TextMarshaler interface
+	textMarshalType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
 )
 
 // ErrMissingRequiredValue returned when a field with required=true contains no value or default
@@ -63,18 +122,169 @@ func (e ErrMissingRequiredValue) Error() string {
 	return fmt.Sprintf("value for this field is required [%s]", e.Value)
 }
 
+// redactedValue replaces the offending value of a redacted FieldError.
+const redactedValue = "[REDACTED]"
+
+// FieldError is returned when a single field fails to unmarshal or marshal.
+// It wraps the underlying cause, so errors.Is and errors.As can still be used
+// to match ErrUnsupportedType, ErrMissingRequiredValue, a *strconv.NumError,
+// etc.
+type FieldError struct {
+	// Keys are the environment variable keys tried for the field
+	Keys []string
+	// Field is the dotted path of the field from the root struct, such as
+	// "Jenkins.BuildNumber"
+	Field string
+	// Type is the Go type of the field
+	Type reflect.Type
+	// Value is the raw value that could not be used, if any
+	Value string
+	// Err is the underlying cause
+	Err error
+}
+
+func (e *FieldError) Error() string {
+	// Fields whose tag cannot be parsed have no keys
+	if len(e.Keys) == 0 {
+		return fmt.Sprintf("env: field %q (%s): %s", e.Field, e.Type, e.Err)
+	}
+	return fmt.Sprintf("env: field %q (%s) for %s: %s", e.Field, e.Type, strings.Join(e.Keys, ","), e.Err)
+}
+
+// Unwrap returns the underlying cause of e.
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// Redact removes the offending value from e, so e can be logged without
+// leaking it. As causes can quote the value in any form, the message of the
+// cause is replaced with a fixed one, naming the violated rule for a
+// *ValidationError, while the cause stays reachable with errors.Is and
+// errors.As.
+func (e *FieldError) Redact() {
+	if e.Value == "" || e.Value == redactedValue {
+		return
+	}
+	msg := fmt.Sprintf("invalid value for %s", e.Type)
+	var validationErr *ValidationError
+	if errors.As(e.Err, &validationErr) {
+		msg = (&ValidationError{Rule: validationErr.Rule, Value: redactedValue}).Error()
+	}
+	e.Err = &redactedError{msg: msg, err: e.Err}
+	e.Value = redactedValue
+}
+
+// redactedError replaces the message of err with a redacted one.
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
 // Unmarshal parses an EnvSet and stores the result in the value pointed to by
-// v. Fields that are matched in v will be deleted from EnvSet, resulting in
-// an EnvSet with the remaining environment variables. If v is nil or not a
-// pointer to a struct, Unmarshal returns an ErrInvalidValue.
+// v. The variables of fields that are matched in v will be deleted from
+// EnvSet, resulting in an EnvSet with the remaining environment variables.
+// Only the key a field was read from is deleted, and not its other keys,
+// unless WithDeleteAliases is used. Use WithKeepEnvSet to leave EnvSet
+// untouched. If v is nil or not a pointer to a struct, Unmarshal returns an
+// ErrInvalidValue.
 //
 // Fields tagged with "env" will have the unmarshalled EnvSet of the matching
 // key from EnvSet. If the tagged field is not exported, Unmarshal returns
 // ErrUnexportedField.
 //
-// If the field has a type that is unsupported, Unmarshal returns
-// ErrUnsupportedType.
+// Fields whose type implements Unmarshaler, or encoding.TextUnmarshaler, are
+// unmarshalled with it, Unmarshaler taking precedence. This includes pointer
+// fields and slice elements. If the field has a type that is otherwise
+// unsupported, Unmarshal returns ErrUnsupportedType.
+//
+// Errors about a specific field are returned as a *FieldError wrapping the
+// cause, so errors.Is(err, ErrUnsupportedType) and the like keep working.
+//
+// Slice fields are split on the "separator" tag option, "|" by default, and an
+// empty value sets an empty slice rather than a slice with one empty element.
+// Map fields are split into entries on the "separator" tag option, "," by
+// default, and each entry into a key and a value on the "kvseparator" tag
+// option, ":" by default. For example, `env:"TAGS"` on a map[string]string
+// field reads TAGS=team:core,env:prod.
+//
+// A map field with string keys can instead collect every variable starting
+// with a prefix, by using a key ending with "*". For example, a
+// map[string]bool field tagged `env:"FEATURE_FLAG_*"` reads FEATURE_FLAG_BETA
+// into the "BETA" entry. Matched variables are deleted from EnvSet as well.
+//
+// Keys and option values can contain commas when they are single quoted, such
+// as in `env:"TAGS,separator=','"` or `env:"JSON,default='{\"a\":1,\"b\":2}'"`, or
+// when the commas are escaped with a backslash. Malformed tags make Unmarshal
+// return ErrInvalidTag.
+//
+// Values can be validated once parsed with the "min", "max", "oneof",
+// "pattern" and "notempty" tag options, see validate for details. Violations
+// are reported as a *ValidationError.
+//
+// A field tagged with "file=true" treats the value, or its default, as the
+// path of a file and is set to the content of that file instead, without its
+// trailing newline. See WithFileFallback and WithFS for more ways to read
+// values from files.
+//
+// Nested structs are traversed recursively. A nested struct field tagged with
+// "envPrefix" has the prefix prepended to the keys of all the fields it
+// contains, including the ones of its own nested structs. For example, a field
+// tagged `envPrefix:"PRIMARY_DB_"` whose struct has a field tagged `env:"HOST"`
+// reads PRIMARY_DB_HOST.
+//
+// A slice of structs, or of pointers to structs, tagged with "envPrefix" reads
+// its elements from indexed keys. For example, a field tagged
+// `envPrefix:"UPSTREAM_"` reads its first element from UPSTREAM_0_HOST,
+// UPSTREAM_0_PORT, etc., its second one from UPSTREAM_1_HOST, etc., stopping at
+// the first index without any key.
+//
+// Fields tagged with "exclusive=<group>" are mutually exclusive: at most one
+// field of the group can be set. Fields tagged with "together=<group>" must be
+// set together or not at all. Groups are scoped to the struct declaring them,
+// and a field counts as set when one of its keys is in EnvSet, defaults
+// aside. Violations are reported as a *GroupError.
+//
+// After its fields are set, a struct implementing Validator has its Validate
+// method called, nested structs first and the root struct last. Validate is
+// not called on structs with fields that failed.
+//
+// Fields tagged with "secret=true", and Secret[T] fields, hold secrets, whose
+// values are masked in the Report filled with WithReport and left out of the
+// errors of Unmarshal.
+//
+// Unmarshal stops at the first field that fails. Use a Decoder created with
+// WithAllErrors to collect the failures of every field instead.
 func Unmarshal(es EnvSet, v interface{}) error {
+	return NewDecoder().Unmarshal(es, v)
+}
+
+// decodeState holds the state of a single call to Unmarshal.
+type decodeState struct {
+	config *config
+	es     EnvSet
+	// errs collects field errors when config.allErrors is set
+	errs Errors
+	// expander expands variable references when config.expand is set
+	expander *expander
+	// known records the keys read by fields when config.ownedPrefixes is
+	// set
+	known *knownKeys
+	// root is the type of the struct being unmarshalled
+	root reflect.Type
+	// claims are the keys read by the fields of root, which prefixed map
+	// fields leave to them, computed on the first such field
+	claims *keyClaims
+}
+
+func unmarshal(c *config, es EnvSet, v interface{}) error {
 	rv := reflect.ValueOf(v)
 	if rv.Kind() != reflect.Ptr || rv.IsNil() {
 		return ErrInvalidValue
@@ -85,69 +295,494 @@ func Unmarshal(es EnvSet, v interface{}) error {
 		return ErrInvalidValue
 	}
 
-	t := rv.Type()
+	if c.keepEnvSet {
+		es = maps.Clone(es)
+	}
+	if c.report != nil {
+		*c.report = nil
+	}
+
+	d := &decodeState{config: c, es: es, root: rv.Type()}
+	if c.expand {
+		d.expander = newExpander(es)
+	}
+	if len(c.ownedPrefixes) > 0 {
+		d.known = &knownKeys{}
+	}
+	if err := d.unmarshalStruct(rv, "", ""); err != nil {
+		return err
+	}
+	if d.known != nil {
+		for _, err := range d.known.unknown(c, d.es) {
+			if err := d.fail(err); err != nil {
+				return err
+			}
+		}
+	}
+	if len(d.errs) > 0 {
+		return d.errs
+	}
+	return nil
+}
+
+// fail records a field error. It returns err if decoding has to stop, or nil
+// if the Decoder collects all errors and the walk can carry on.
+func (d *decodeState) fail(err error) error {
+	if !d.config.allErrors {
+		return err
+	}
+	d.errs = append(d.errs, err)
+	return nil
+}
+
+// unmarshalStruct sets the fields of rv, a struct found at the given field
+// path. The keys of its fields are prepended with prefix.
+func (d *decodeState) unmarshalStruct(rv reflect.Value, path, prefix string) error {
+	var (
+		t = rv.Type()
+		// errCount is the number of errors collected before rv
+		errCount = len(d.errs)
+		groups   fieldGroups
+	)
 	for i := range rv.NumField() {
 		valueField := rv.Field(i)
+		typeField := t.Field(i)
+		fieldPath := joinPath(path, typeField.Name)
 		if valueField.Kind() == reflect.Struct {
 			if !valueField.Addr().CanInterface() {
 				continue
 			}
-			if err := Unmarshal(es, valueField.Addr().Interface()); err != nil {
+			if err := d.unmarshalStruct(valueField, fieldPath, prefix+typeField.Tag.Get(d.config.prefixTagName())); err != nil {
 				return err
 			}
 		}
 
-		typeField := t.Field(i)
-		tag := typeField.Tag.Get("env")
+		if envPrefix, ok := typeField.Tag.Lookup(d.config.prefixTagName()); ok && isStructSlice(typeField.Type) {
+			if !valueField.CanSet() {
+				continue
+			}
+			if err := d.unmarshalStructSlice(valueField, fieldPath, prefix+envPrefix); err != nil {
+				return err
+			}
+			continue
+		}
+
+		tag := typeField.Tag.Get(d.config.tagName())
 		if tag == "" {
 			continue
 		}
 
+		envTag, err := parseTag(tag)
+		envTag.addPrefix(prefix)
+		if isSecretType(typeField.Type) {
+			envTag.Secret = true
+		}
+		if d.known != nil {
+			d.known.add(d.config, envTag)
+		}
+		fieldErr := func(value string, err error) error {
+			fe := &FieldError{
+				Keys:  envTag.Keys,
+				Field: fieldPath,
+				Type:  typeField.Type,
+				Value: value,
+				Err:   err,
+			}
+			if envTag.Secret {
+				fe.Redact()
+			}
+			return d.fail(fe)
+		}
+
+		if err == nil {
+			err = d.config.checkTag(envTag)
+		}
+		if err != nil {
+			if err := fieldErr("", err); err != nil {
+				return err
+			}
+			continue
+		}
+
 		if !valueField.CanSet() {
-			return ErrUnexportedField
+			if err := fieldErr("", ErrUnexportedField); err != nil {
+				return err
+			}
+			continue
 		}
 
-		envTag := parseTag(tag)
+		if prefixes := envTag.prefixes(); len(prefixes) > 0 {
+			if d.claims == nil {
+				d.claims = d.config.claimedKeys(d.root)
+			}
+			found, value, err := d.unmarshalPrefixed(valueField, fieldPath, prefixes, envTag)
+			groups.add(envTag, found)
+			if err == nil && !found {
+				d.record(fieldPath, reportUnset, "", envTag)
+			}
+			if err == nil && !found && envTag.Required {
+				err = &ErrMissingRequiredValue{Value: envTag.Keys[0]}
+			}
+			if err == nil && found {
+				err = validate(d.config, valueField, envTag)
+			}
+			if err != nil {
+				if err := fieldErr(value, err); err != nil {
+					return err
+				}
+			}
+			continue
+		}
 
 		var (
 			envValue string
 			ok       bool
+			// matchedKey is the key of the variable the value comes from,
+			// if any
+			matchedKey string
+			// fileKey is the key of the variable holding the path of the
+			// file to read the value from, if the value comes from a
+			// "_FILE" variable
+			fileKey string
 		)
 		for _, envKey := range envTag.Keys {
-			envValue, ok = es[envKey]
+			envValue, ok = d.es[envKey]
 			if ok {
+				matchedKey = envKey
 				break
 			}
 		}
 
+		if !ok && d.config.fileFallback {
+			for _, envKey := range envTag.Keys {
+				envValue, ok = d.es[envKey+fileSuffix]
+				if ok {
+					matchedKey = envKey + fileSuffix
+					fileKey = matchedKey
+					break
+				}
+			}
+		}
+
+		groups.add(envTag, ok)
+		switch {
+		case ok:
+			d.record(fieldPath, matchedKey, envValue, envTag)
+		case envTag.Default != "":
+			d.record(fieldPath, reportDefault, envTag.Default, envTag)
+		default:
+			d.record(fieldPath, reportUnset, "", envTag)
+		}
+
 		if !ok {
 			if envTag.Default != "" {
 				envValue = envTag.Default
 			} else if envTag.Required {
-				return &ErrMissingRequiredValue{Value: envTag.Keys[0]}
+				if err := fieldErr("", &ErrMissingRequiredValue{Value: envTag.Keys[0]}); err != nil {
+					return err
+				}
+				continue
 			} else {
 				continue
 			}
 		}
 
-		if err := set(typeField.Type, valueField, envValue, envTag.Separator); err != nil {
+		if d.expander != nil {
+			// Expand variables through their key rather than their value,
+			// so that cycles are reported from the variable of the field
+			var (
+				expanded string
+				err      error
+			)
+			if matchedKey != "" {
+				expanded, err = d.expander.lookup(matchedKey)
+			} else {
+				expanded, err = d.expander.expand(envValue)
+			}
+			if err != nil {
+				if err := fieldErr(envValue, err); err != nil {
+					return err
+				}
+				continue
+			}
+			envValue = expanded
+		}
+
+		if envTag.File || fileKey != "" {
+			content, err := d.config.readFile(envValue)
+			if err != nil {
+				if err := fieldErr(envValue, err); err != nil {
+					return err
+				}
+				continue
+			}
+			envValue = content
+		}
+
+		if err := set(d.config, typeField.Type, valueField, envValue, envTag); err != nil {
+			if err := fieldErr(envValue, err); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := validate(d.config, valueField, envTag); err != nil {
+			if err := fieldErr(envValue, err); err != nil {
+				return err
+			}
+			continue
+		}
+		if d.config.deleteAliases {
+			for _, envKey := range envTag.Keys {
+				delete(d.es, envKey)
+				if d.config.fileFallback {
+					delete(d.es, envKey+fileSuffix)
+				}
+			}
+		} else if matchedKey != "" {
+			delete(d.es, matchedKey)
+		}
+	}
+
+	for _, err := range groups.check() {
+		if err := d.fail(&StructError{Field: path, Type: t, Err: err}); err != nil {
+			return err
+		}
+	}
+
+	// Only validate structs whose fields were all set successfully
+	if len(d.errs) > errCount {
+		return nil
+	}
+	if v, ok := rv.Addr().Interface().(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return d.fail(&StructError{Field: path, Type: t, Err: err})
+		}
+	}
+	return nil
+}
+
+// keyClaims are the keys read by the fields of a struct type, which prefixed
+// map fields leave to them. The keys of the elements of slices of structs are
+// matched through elems, as their indexes are only known from the EnvSet.
+type keyClaims struct {
+	keys  map[string]bool
+	elems []elemClaims
+}
+
+// elemClaims are the keys read by the elements of a slice of structs, which
+// start with prefix followed by an index and "_".
+type elemClaims struct {
+	prefix string
+	claims *keyClaims
+}
+
+// has reports whether key is claimed by a field.
+func (k *keyClaims) has(key string) bool {
+	if k.keys[key] {
+		return true
+	}
+	for _, elem := range k.elems {
+		rest, ok := strings.CutPrefix(key, elem.prefix)
+		if !ok {
+			continue
+		}
+		digits := len(rest) - len(strings.TrimLeft(rest, "0123456789"))
+		if digits > 0 && strings.HasPrefix(rest[digits:], "_") && elem.claims.has(rest[digits+1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// claimedKeys returns the keys read by the fields of t, a struct type, and of
+// its nested structs and slices of structs, including their "_FILE" variants
+// with WithFileFallback. Keys ending with "*" and fields with invalid tags are
+// left out.
+func (c *config) claimedKeys(t reflect.Type) *keyClaims {
+	k := &keyClaims{keys: make(map[string]bool)}
+	c.addClaims(k, t, "", make(map[reflect.Type]*keyClaims))
+	return k
+}
+
+// addClaims adds to k the keys read by the fields of t, prepended with prefix.
+// The claims of the elements of slices of structs are shared through elems, by
+// type, so that self-referencing types are walked once.
+func (c *config) addClaims(k *keyClaims, t reflect.Type, prefix string, elems map[reflect.Type]*keyClaims) {
+	for i := range t.NumField() {
+		typeField := t.Field(i)
+		if typeField.Type.Kind() == reflect.Struct && typeField.IsExported() {
+			c.addClaims(k, typeField.Type, prefix+typeField.Tag.Get(c.prefixTagName()), elems)
+		}
+
+		if envPrefix, ok := typeField.Tag.Lookup(c.prefixTagName()); ok && isStructSlice(typeField.Type) {
+			elemType := typeField.Type.Elem()
+			if elemType.Kind() == reflect.Ptr {
+				elemType = elemType.Elem()
+			}
+			elem, ok := elems[elemType]
+			if !ok {
+				elem = &keyClaims{keys: make(map[string]bool)}
+				elems[elemType] = elem
+				c.addClaims(elem, elemType, "", elems)
+			}
+			k.elems = append(k.elems, elemClaims{prefix: prefix + envPrefix, claims: elem})
+			continue
+		}
+
+		tagString := typeField.Tag.Get(c.tagName())
+		if tagString == "" {
+			continue
+		}
+		envTag, err := parseTag(tagString)
+		if err != nil {
+			continue
+		}
+		envTag.addPrefix(prefix)
+		for _, key := range envTag.Keys {
+			if strings.HasSuffix(key, "*") {
+				continue
+			}
+			k.keys[key] = true
+			if c.fileFallback {
+				k.keys[key+fileSuffix] = true
+			}
+		}
+	}
+}
+
+// unmarshalStructSlice fills f, a slice of structs or of pointers to structs
+// found at the given field path, with one element per index i for which the
+// EnvSet has keys starting with prefix followed by "i_". Each element is
+// unmarshalled like a nested struct with that prefix, stopping at the first
+// missing index.
+func (d *decodeState) unmarshalStructSlice(f reflect.Value, path, prefix string) error {
+	t := f.Type()
+	elemType := t.Elem()
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+
+	dest := reflect.MakeSlice(t, 0, 0)
+	for i := 0; ; i++ {
+		elemPrefix := prefix + strconv.Itoa(i) + "_"
+		if !d.hasPrefix(elemPrefix) {
+			break
+		}
+		elem := reflect.New(elemType)
+		if err := d.unmarshalStruct(elem.Elem(), fmt.Sprintf("%s[%d]", path, i), elemPrefix); err != nil {
 			return err
 		}
-		delete(es, tag)
+		if t.Elem().Kind() != reflect.Ptr {
+			elem = elem.Elem()
+		}
+		dest = reflect.Append(dest, elem)
 	}
 
+	if dest.Len() > 0 {
+		f.Set(dest)
+	}
 	return nil
 }
 
-func set(t reflect.Type, f reflect.Value, value, sliceSeparator string) error {
-	// See if the type implements Unmarshaler and use that first,
-	// otherwise, fallback to the previous logic
+// hasPrefix reports whether any key of the EnvSet starts with prefix.
+func (d *decodeState) hasPrefix(prefix string) bool {
+	for key := range d.es {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// unmarshalPrefixed fills f, a map field with string keys, with every variable
+// of the EnvSet whose key starts with one of prefixes, keyed by the rest of
+// its key. When several prefixes yield the same map key, the first prefix
+// wins. Variables are recorded in the report of the field at the given path
+// and deleted from the EnvSet once used. Variables claimed by other fields are
+// left to them, whatever the order of the fields.
+// unmarshalPrefixed reports whether any variable matched, and the value that
+// failed to parse if it returns an error.
+func (d *decodeState) unmarshalPrefixed(f reflect.Value, path string, prefixes []string, envTag tag) (bool, string, error) {
+	t := f.Type()
+	if t.Kind() != reflect.Map || t.Key().Kind() != reflect.String {
+		return false, "", ErrUnsupportedType
+	}
+
+	dest := reflect.MakeMap(t)
+	var consumed []string
+	for _, prefix := range prefixes {
+		// Sort the matching keys so that errors are reported in a stable order
+		var keys []string
+		for key := range d.es {
+			if strings.HasPrefix(key, prefix) && len(key) > len(prefix) && !d.claims.has(key) {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			name := reflect.ValueOf(strings.TrimPrefix(key, prefix)).Convert(t.Key())
+			if dest.MapIndex(name).IsValid() {
+				continue
+			}
+			value := d.es[key]
+			if d.expander != nil {
+				expanded, err := d.expander.lookup(key)
+				if err != nil {
+					return false, value, fmt.Errorf("%s: %w", key, err)
+				}
+				value = expanded
+			}
+			elem := reflect.New(t.Elem()).Elem()
+			if err := set(d.config, t.Elem(), elem, value, envTag); err != nil {
+				return false, value, fmt.Errorf("%s: %w", key, err)
+			}
+			dest.SetMapIndex(name, elem)
+			consumed = append(consumed, key)
+		}
+	}
+
+	if len(consumed) == 0 {
+		return false, "", nil
+	}
+	f.Set(dest)
+	for _, key := range consumed {
+		d.record(path, key, d.es[key], envTag)
+		delete(d.es, key)
+	}
+	return true, "", nil
+}
+
+// implementsUnmarshaler reports whether t implements Unmarshaler or
+// encoding.TextUnmarshaler.
+func implementsUnmarshaler(t reflect.Type) bool {
+	return t.Implements(unmarshalType) || t.Implements(textUnmarshalType)
+}
+
+// set parses value into f, a value of type t. The separators of envTag are
+// used to split slice and map values.
+func set(c *config, t reflect.Type, f reflect.Value, value string, envTag tag) error {
+	// Registered parse functions take precedence over everything else
+	if ok, err := c.registry.parse(t, f, value); ok {
+		return err
+	}
+
+	// Secrets are set like the value they hold
+	if f.CanAddr() && f.Addr().CanInterface() {
+		if s, ok := f.Addr().Interface().(settableSecret); ok {
+			inner := s.secretField()
+			return set(c, inner.Type(), inner, value, envTag)
+		}
+	}
+
+	// See if the type implements Unmarshaler or encoding.TextUnmarshaler and
+	// use that first, otherwise, fallback to the previous logic
 	var isUnmarshaler bool
 	isPtr := t.Kind() == reflect.Ptr
 	if isPtr {
-		isUnmarshaler = t.Implements(unmarshalType) && f.CanInterface()
+		isUnmarshaler = implementsUnmarshaler(t) && f.CanInterface()
 	} else if f.CanAddr() {
-		isUnmarshaler = f.Addr().Type().Implements(unmarshalType) && f.Addr().CanInterface()
+		isUnmarshaler = implementsUnmarshaler(f.Addr().Type()) && f.Addr().CanInterface()
 	}
 
 	if isUnmarshaler {
@@ -160,21 +795,26 @@ func set(t reflect.Type, f reflect.Value, value, sliceSeparator string) error {
 			// And for scalars, we need the pointer to be able to modify the value
 			ptr = f.Addr()
 		}
-		if u, ok := ptr.Interface().(Unmarshaler); ok {
-			if err := u.UnmarshalEnvironmentValue(value); err != nil {
-				return err
-			}
-			if isPtr {
-				f.Set(ptr)
-			}
-			return nil
+		var err error
+		switch u := ptr.Interface().(type) {
+		case Unmarshaler:
+			err = u.UnmarshalEnvironmentValue(value)
+		case encoding.TextUnmarshaler:
+			err = u.UnmarshalText([]byte(value))
+		}
+		if err != nil {
+			return err
+		}
+		if isPtr {
+			f.Set(ptr)
 		}
+		return nil
 	}
 
 	switch t.Kind() {
 	case reflect.Ptr:
 		ptr := reflect.New(t.Elem())
-		if err := set(t.Elem(), ptr.Elem(), value, sliceSeparator); err != nil {
+		if err := set(c, t.Elem(), ptr.Elem(), value, envTag); err != nil {
 			return err
 		}
 		f.Set(ptr)
@@ -221,23 +861,54 @@ func set(t reflect.Type, f reflect.Value, value, sliceSeparator string) error {
 		}
 		f.SetUint(v)
 	case reflect.Slice:
-		if sliceSeparator == "" {
-			sliceSeparator = "|"
+		if value == "" {
+			// Marshal writes empty slices as empty strings
+			f.Set(reflect.MakeSlice(t, 0, 0))
+			return nil
 		}
-		values := strings.Split(value, sliceSeparator)
-		switch t.Elem().Kind() {
-		case reflect.String:
+		values := strings.Split(value, c.sliceSeparator(envTag))
+		switch {
+		case t.Elem() == stringType && !c.registry.hasParser(stringType):
 			// already []string, just set directly
-			f.Set(reflect.ValueOf(values))
+			f.Set(reflect.ValueOf(values).Convert(t))
 		default:
 			dest := reflect.MakeSlice(reflect.SliceOf(t.Elem()), len(values), len(values))
 			for i, v := range values {
-				if err := set(t.Elem(), dest.Index(i), v, sliceSeparator); err != nil {
+				if err := set(c, t.Elem(), dest.Index(i), v, envTag); err != nil {
 					return err
 				}
 			}
 			f.Set(dest)
 		}
+	case reflect.Map:
+		separator, kvSeparator := envTag.Separator, envTag.KVSeparator
+		if separator == "" {
+			separator = defaultMapSeparator
+		}
+		if kvSeparator == "" {
+			kvSeparator = defaultKVSeparator
+		}
+		dest := reflect.MakeMap(t)
+		if value != "" {
+			for _, entry := range strings.Split(value, separator) {
+				k, v, ok := strings.Cut(entry, kvSeparator)
+				if !ok {
+					return fmt.Errorf("map entry %q is missing the key/value separator %q", entry, kvSeparator)
+				}
+				// Keys and values are parsed with the default separators,
+				// the ones of the tag being taken by the map itself
+				key := reflect.New(t.Key()).Elem()
+				if err := set(c, t.Key(), key, k, tag{}); err != nil {
+					return err
+				}
+				elem := reflect.New(t.Elem()).Elem()
+				if err := set(c, t.Elem(), elem, v, tag{}); err != nil {
+					return err
+				}
+				dest.SetMapIndex(key, elem)
+			}
+		}
+		f.Set(dest)
 	default:
 		return ErrUnsupportedType
 	}
@@ -256,23 +927,43 @@ func set(t reflect.Type, f reflect.Value, value, sliceSeparator string) error {
 //
 // If the field has a type that is unsupported, UnmarshalFromEnviron returns
 // ErrUnsupportedType.
+//
+// Errors about a specific field are returned as a *FieldError wrapping the
+// cause.
 func UnmarshalFromEnviron(v interface{}) (EnvSet, error) {
-	es, err := EnvironToEnvSet(os.Environ())
-	if err != nil {
-		return nil, err
-	}
-
-	return es, Unmarshal(es, v)
+	return NewDecoder().UnmarshalFromEnviron(v)
 }
 
 // Marshal returns an EnvSet of v. If v is nil or not a pointer, Marshal returns
 // an ErrInvalidValue.
 //
 // Marshal uses fmt.Sprintf to transform encountered values to its default
-// string format. Values without the "env" field tag are ignored.
+// string format, unless they implement Marshaler or encoding.TextMarshaler,
+// Marshaler taking precedence. Slices are encoded element by element, the
+// same way, and joined with the separator Unmarshal expects, so that
+// Unmarshal reads back what Marshal writes, nil slices and maps coming back
+// empty. Slices that Unmarshal cannot read back, with a nil element, an
+// element holding the separator, or a single empty element, make Marshal
+// return an error wrapping ErrUnrepresentable. Values without the "env" field
+// tag are ignored.
+//
+// Map fields are encoded with the same separators Unmarshal expects, with
+// their entries sorted by key. Keys holding either separator, and values
+// holding the entry separator, make Marshal return an error wrapping
+// ErrUnrepresentable. Map fields collecting prefixed variables are expanded
+// back into one variable per entry.
 //
-// Nested structs are traversed recursively.
+// Nested structs are traversed recursively, honoring their "envPrefix" field
+// tag the same way Unmarshal does. Slices of structs tagged with "envPrefix"
+// are expanded into the indexed keys Unmarshal expects.
+//
+// Errors about a specific field are returned as a *FieldError wrapping the
+// cause.
 func Marshal(v interface{}) (EnvSet, error) {
+	return NewEncoder().Marshal(v)
+}
+
+func marshal(c *config, v interface{}) (EnvSet, error) {
 	rv := reflect.ValueOf(v)
 	if rv.Kind() != reflect.Ptr || rv.IsNil() {
 		return nil, ErrInvalidValue
@@ -284,68 +975,271 @@ func Marshal(v interface{}) (EnvSet, error) {
 	}
 
 	es := make(EnvSet)
+	if err := marshalStruct(c, es, rv, "", ""); err != nil {
+		return nil, err
+	}
+	return es, nil
+}
+
+// marshalStruct adds the fields of rv, a struct found at the given field
+// path, to es. The keys of its fields are prepended with prefix.
+func marshalStruct(c *config, es EnvSet, rv reflect.Value, path, prefix string) error {
 	t := rv.Type()
 	for i := range rv.NumField() {
 		valueField := rv.Field(i)
+		typeField := t.Field(i)
+		fieldPath := joinPath(path, typeField.Name)
 		if valueField.Kind() == reflect.Struct {
 			if !valueField.Addr().CanInterface() {
 				continue
 			}
 
-			nes, err := Marshal(valueField.Addr().Interface())
-			if err != nil {
-				return nil, err
+			if err := marshalStruct(c, es, valueField, fieldPath, prefix+typeField.Tag.Get(c.prefixTagName())); err != nil {
+				return err
 			}
+		}
 
-			for k, v := range nes {
-				es[k] = v
+		if envPrefix, ok := typeField.Tag.Lookup(c.prefixTagName()); ok && isStructSlice(typeField.Type) {
+			if !valueField.CanInterface() {
+				continue
+			}
+			for i := range valueField.Len() {
+				elem := valueField.Index(i)
+				if elem.Kind() == reflect.Ptr {
+					// Keep the indexes contiguous, as Unmarshal stops at the
+					// first missing one
+					if elem.IsNil() {
+						elem = reflect.New(elem.Type().Elem())
+					}
+					elem = elem.Elem()
+				}
+				elemPrefix := prefix + envPrefix + strconv.Itoa(i) + "_"
+				if err := marshalStruct(c, es, elem, fmt.Sprintf("%s[%d]", fieldPath, i), elemPrefix); err != nil {
+					return err
+				}
 			}
+			continue
 		}
 
-		typeField := t.Field(i)
-		tag := typeField.Tag.Get("env")
+		tag := typeField.Tag.Get(c.tagName())
 		if tag == "" {
 			continue
 		}
 
-		envKeys := strings.Split(tag, ",")
+		envTag, err := parseTag(tag)
+		envTag.addPrefix(prefix)
+		if isSecretType(typeField.Type) {
+			envTag.Secret = true
+		}
+		fieldErr := func(err error) error {
+			return &FieldError{Keys: envTag.Keys, Field: fieldPath, Type: typeField.Type, Err: err}
+		}
+
+		if err == nil {
+			err = c.checkTag(envTag)
+		}
+		if err != nil {
+			return fieldErr(err)
+		}
+
+		if !valueField.CanInterface() {
+			return fieldErr(ErrUnexportedField)
+		}
 
-		var el interface{}
 		if typeField.Type.Kind() == reflect.Ptr {
 			if valueField.IsNil() {
 				continue
 			}
-			el = valueField.Elem().Interface()
-		} else {
-			el = valueField.Interface()
+			valueField = valueField.Elem()
 		}
 
-		var (
-			err      error
-			envValue string
-		)
-		if m, ok := el.(Marshaler); ok {
-			envValue, err = m.MarshalEnvironmentValue()
+		if prefixes := envTag.prefixes(); len(prefixes) > 0 {
+			if err := marshalPrefixed(c, es, valueField, prefixes, envTag); err != nil {
+				return fieldErr(err)
+			}
+			continue
+		}
+
+		envValue, err := marshalValue(c, valueField, envTag)
+		if err != nil {
+			return fieldErr(err)
+		}
+
+		for _, envKey := range envTag.Keys {
+			es[envKey] = envValue
+		}
+	}
+
+	return nil
+}
+
+// marshalValue returns the environment variable value of v. The separators of
+// envTag are used to join map values.
+func marshalValue(c *config, v reflect.Value, envTag tag) (string, error) {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return "", nil
+	}
+
+	if c.redact && envTag.Secret {
+		envTag.Secret = false
+		value, err := marshalValue(c, v, envTag)
+		if value != "" {
+			value = redactedValue
+		}
+		return value, err
+	}
+
+	// Registered format functions take precedence over everything else
+	if value, ok, err := c.registry.format(v); ok {
+		return value, err
+	}
+
+	// Secrets are marshalled like the value they hold
+	if v.CanInterface() {
+		if s, ok := v.Interface().(secret); ok {
+			envTag.Secret = true
+			return marshalValue(c, s.secretValue(), envTag)
+		}
+	}
+
+	// See if the value, or a pointer to it, implements Marshaler or
+	// encoding.TextMarshaler, in that order, and use that first
+	candidates := []reflect.Value{v}
+	if v.CanAddr() {
+		candidates = append(candidates, v.Addr())
+	}
+	for _, c := range candidates {
+		if m, ok := c.Interface().(Marshaler); ok {
+			return m.MarshalEnvironmentValue()
+		}
+	}
+	for _, c := range candidates {
+		if m, ok := c.Interface().(encoding.TextMarshaler); ok {
+			text, err := m.MarshalText()
+			return string(text), err
+		}
+	}
+
+	if v.Kind() == reflect.Ptr {
+		return marshalValue(c, v.Elem(), envTag)
+	}
+
+	if v.Kind() == reflect.Slice {
+		separator := c.sliceSeparator(envTag)
+		values := make([]string, v.Len())
+		for i := range v.Len() {
+			elem := v.Index(i)
+			if elem.Kind() == reflect.Ptr && elem.IsNil() {
+				return "", fmt.Errorf("%w: element %d is nil", ErrUnrepresentable, i)
+			}
+			value, err := marshalValue(c, elem, tag{})
 			if err != nil {
-				return nil, err
+				return "", err
 			}
-		} else {
-			envValue = fmt.Sprintf("%v", el)
+			if strings.Contains(value, separator) {
+				return "", fmt.Errorf("%w: element %d holds the separator %q", ErrUnrepresentable, i, separator)
+			}
+			values[i] = value
+		}
+		// Unmarshal reads an empty value back as an empty slice
+		if len(values) == 1 && values[0] == "" {
+			return "", fmt.Errorf("%w: single empty element", ErrUnrepresentable)
 		}
+		return strings.Join(values, separator), nil
+	}
 
-		for _, envKey := range envKeys {
-			// Skip keys with '=', as they represent tag options and not environment variable names.
-			if strings.Contains(envKey, "=") {
-				switch strings.ToLower(strings.SplitN(envKey, "=", 2)[0]) {
-				case "separator", "required", "default":
-					continue
-				}
+	if v.Kind() == reflect.Map {
+		separator, kvSeparator := envTag.Separator, envTag.KVSeparator
+		if separator == "" {
+			separator = defaultMapSeparator
+		}
+		if kvSeparator == "" {
+			kvSeparator = defaultKVSeparator
+		}
+		keys := make([]string, 0, v.Len())
+		values := make(map[string]string, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			k, err := marshalValue(c, iter.Key(), tag{})
+			if err != nil {
+				return "", err
 			}
-			es[envKey] = envValue
+			e, err := marshalValue(c, iter.Value(), tag{})
+			if err != nil {
+				return "", err
+			}
+			keys = append(keys, k)
+			values[k] = e
 		}
+		// Sort the keys so that the output is deterministic
+		sort.Strings(keys)
+		entries := make([]string, len(keys))
+		for i, k := range keys {
+			// Keys and values are left out of errors, as they can be secrets
+			if strings.Contains(k, separator) || strings.Contains(k, kvSeparator) {
+				return "", fmt.Errorf("%w: key of entry %d holds a separator", ErrUnrepresentable, i)
+			}
+			if strings.Contains(values[k], separator) {
+				return "", fmt.Errorf("%w: value of entry %d holds the separator %q", ErrUnrepresentable, i, separator)
+			}
+			entries[i] = k + kvSeparator + values[k]
+		}
+		return strings.Join(entries, separator), nil
 	}
 
-	return es, nil
+	return fmt.Sprintf("%v", v.Interface()), nil
+}
+
+// marshalPrefixed adds an entry to es for every entry of v, a map with string
+// keys, under each of prefixes.
+func marshalPrefixed(c *config, es EnvSet, v reflect.Value, prefixes []string, envTag tag) error {
+	if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+		return ErrUnsupportedType
+	}
+
+	iter := v.MapRange()
+	for iter.Next() {
+		value, err := marshalValue(c, iter.Value(), envTag)
+		if err != nil {
+			return err
+		}
+		for _, prefix := range prefixes {
+			es[prefix+iter.Key().String()] = value
+		}
+	}
+	return nil
+}
+
+// implementsMarshaler reports whether t, or a pointer to t, implements
+// Marshaler or encoding.TextMarshaler.
+func implementsMarshaler(t reflect.Type) bool {
+	for _, t := range []reflect.Type{t, reflect.PointerTo(t)} {
+		if t.Implements(marshalType) || t.Implements(textMarshalType) {
+			return true
+		}
+	}
+	return false
+}
+
+// isStructSlice reports whether t is a slice of structs or of pointers to
+// structs.
+func isStructSlice(t reflect.Type) bool {
+	if t.Kind() != reflect.Slice {
+		return false
+	}
+	elemType := t.Elem()
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	return elemType.Kind() == reflect.Struct
+}
+
+// joinPath appends the name of a field to the dotted path of its parent.
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
 }
 
 // tag is a struct used to store the parsed "env" field tag when unmarshalling.
@@ -356,32 +1250,207 @@ type tag struct {
 	Default string
 	// Required is used to specify that the field is required
 	Required bool
-	// Separator is used to split the value of a slice field
+	// Separator is used to split the value of a slice field, or the entries of
+	// a map field
 	Separator string
+	// KVSeparator is used to split the key from the value of map entries
+	KVSeparator string
+	// File is used to specify that the value is the path of a file to read
+	// the actual value from
+	File bool
+	// Min is used to specify the minimum value, or length, of the field
+	Min string
+	// Max is used to specify the maximum value, or length, of the field
+	Max string
+	// OneOf is used to specify the allowed values of the field
+	OneOf []string
+	// Pattern is used to specify a regular expression the value of the field
+	// must match
+	Pattern string
+	// NotEmpty is used to specify that the value of the field must not be
+	// empty
+	NotEmpty bool
+	// Exclusive is used to specify the group of fields of which at most one
+	// can be set
+	Exclusive string
+	// Together is used to specify the group of fields that must be set
+	// together or not at all
+	Together string
+	// Secret is used to specify that the value of the field is a secret
+	Secret bool
+	// Unknown is used to store the options that are not supported
+	Unknown []string
 }
 
-// parseTag is used in the Unmarshal function to parse the "env" field tags
-// into a tag struct for use in the set function.
-func parseTag(tagString string) tag {
+// parseTag is used in the Unmarshal and Marshal functions to parse the "env"
+// field tags into a tag struct for use in the set function.
+//
+// The tag is a comma separated list of keys and key=value options. Single
+// quotes and backslashes can be used to include commas in keys and option
+// values, such as in default='a,b' or separator=\,. A single quote only opens
+// a quoted section at the start of a key or option value, and is kept as is
+// elsewhere, such as in default=don't. Within and outside of quotes, a
+// backslash escapes a following comma, single quote or backslash, and is kept
+// as is otherwise. After the first key, the boolean options required, file,
+// notempty and secret can be given bare, without "=true", when spelled in
+// lowercase. If the tag is malformed, parseTag returns an error wrapping
+// ErrInvalidTag.
+func parseTag(tagString string) (tag, error) {
 	var t tag
-	envKeys := strings.Split(tagString, ",")
-	for _, key := range envKeys {
-		if !strings.Contains(key, "=") {
-			t.Keys = append(t.Keys, key)
+	items, err := splitTag(tagString)
+	if err != nil {
+		return t, err
+	}
+	for i, item := range items {
+		if !item.option && (i == 0 || !isBoolOption(item.key)) {
+			t.Keys = append(t.Keys, item.key)
 			continue
 		}
-		keyData := strings.SplitN(key, "=", 2)
-		switch strings.ToLower(keyData[0]) {
+		if !item.option {
+			// a bare boolean option is set
+			item.value = "true"
+		}
+		switch strings.ToLower(item.key) {
 		case tagKeyDefault:
-			t.Default = keyData[1]
+			t.Default = item.value
 		case tagKeyRequired:
-			t.Required = strings.ToLower(keyData[1]) == "true"
+			t.Required = strings.ToLower(item.value) == "true"
 		case tagKeySeparator:
-			t.Separator = keyData[1]
+			t.Separator = item.value
+		case tagKeyKVSeparator:
+			t.KVSeparator = item.value
+		case tagKeyFile:
+			t.File = strings.ToLower(item.value) == "true"
+		case tagKeyMin:
+			t.Min = item.value
+		case tagKeyMax:
+			t.Max = item.value
+		case tagKeyOneOf:
+			t.OneOf = strings.Split(item.value, "|")
+		case tagKeyPattern:
+			t.Pattern = item.value
+		case tagKeyNotEmpty:
+			t.NotEmpty = strings.ToLower(item.value) == "true"
+		case tagKeyExclusive:
+			t.Exclusive = item.value
+		case tagKeyTogether:
+			t.Together = item.value
+		case tagKeySecret:
+			t.Secret = strings.ToLower(item.value) == "true"
 		default:
-			// just ignoring unsupported keys
+			// just ignoring unsupported keys, unless the tags are checked
+			// strictly
+			t.Unknown = append(t.Unknown, item.key)
+		}
+	}
+	if len(t.Keys) == 0 {
+		return t, fmt.Errorf("%w: no key in %q", ErrInvalidTag, tagString)
+	}
+	return t, nil
+}
+
+// isBoolOption reports whether name is the lowercase name of a boolean tag
+// option, which parseTag accepts without a value. Other spellings are keys, so
+// that aliases such as FILE keep working.
+func isBoolOption(name string) bool {
+	switch name {
+	case tagKeyRequired, tagKeyFile, tagKeyNotEmpty, tagKeySecret:
+		return true
+	}
+	return false
+}
+
+// tagItem is a key or an option of an "env" field tag.
+type tagItem struct {
+	// key is the key, or the name of the option
+	key string
+	// value is the value of the option
+	value string
+	// option is set if the item is a key=value option
+	option bool
+}
+
+// splitTag splits an "env" field tag into its items, following the grammar
+// described in parseTag.
+func splitTag(tagString string) ([]tagItem, error) {
+	var (
+		items  []tagItem
+		item   tagItem
+		b      strings.Builder
+		quoted bool
+		// start is set at the start of a key or option value, where a single
+		// quote opens a quoted section
+		start = true
+	)
+	flush := func() error {
+		if item.option {
+			item.value = b.String()
+		} else {
+			item.key = b.String()
+		}
+		if item.key == "" {
+			return fmt.Errorf("%w: empty key or option name in %q", ErrInvalidTag, tagString)
+		}
+		items = append(items, item)
+		item = tagItem{}
+		b.Reset()
+		start = true
+		return nil
+	}
+
+	for i := 0; i < len(tagString); i++ {
+		c := tagString[i]
+		switch {
+		case c == '\\' && i+1 < len(tagString) && strings.IndexByte(`,'\`, tagString[i+1]) >= 0:
+			i++
+			b.WriteByte(tagString[i])
+		case c == '\'' && (quoted || start):
+			quoted = !quoted
+		case quoted:
+			b.WriteByte(c)
+		case c == '=' && !item.option:
+			item.key = b.String()
+			item.option = true
+			b.Reset()
+			start = true
+			continue
+		case c == ',':
+			if err := flush(); err != nil {
+				return nil, err
+			}
 			continue
+		default:
+			b.WriteByte(c)
+		}
+		start = false
+	}
+	if quoted {
+		return nil, fmt.Errorf("%w: unterminated quote in %q", ErrInvalidTag, tagString)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// addPrefix prepends prefix to the keys of t.
+func (t *tag) addPrefix(prefix string) {
+	if prefix == "" {
+		return
+	}
+	for i, key := range t.Keys {
+		t.Keys[i] = prefix + key
+	}
+}
+
+// prefixes returns the keys of t that end with "*", without the "*". Such keys
+// select every variable whose key starts with them.
+func (t tag) prefixes() []string {
+	var prefixes []string
+	for _, key := range t.Keys {
+		if strings.HasSuffix(key, "*") {
+			prefixes = append(prefixes, strings.TrimSuffix(key, "*"))
 		}
 	}
-	return t
+	return prefixes
 }
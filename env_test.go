@@ -17,8 +17,14 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/netip"
 	"os"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -82,7 +88,7 @@ type ValidStruct struct {
 }
 
 type UnsupportedStruct struct {
-	Timestamp time.Time `env:"TIMESTAMP"`
+	Complex complex128 `env:"COMPLEX"`
 }
 
 type UnexportedStruct struct {
@@ -337,7 +343,7 @@ func TestUnmarshalInvalid(t *testing.T) {
 func TestUnmarshalUnsupported(t *testing.T) {
 	t.Parallel()
 	var (
-		environ           = map[string]string{"TIMESTAMP": "2016-07-15T12:00:00.000Z"}
+		environ           = map[string]string{"COMPLEX": "1+2i"}
 		unsupportedStruct UnsupportedStruct
 	)
 
@@ -462,25 +468,24 @@ func TestUnmarshalRequiredValues(t *testing.T) {
 
 	// Try missing REQUIRED_VAL and REQUIRED_VAL_MORE
 	err := Unmarshal(environ, &requiredValuesStruct)
-	if err == nil {
-		t.Errorf("Expected error 'ErrMissingRequiredValue' but got '%s'", err)
-	}
-	errMissing := ErrMissingRequiredValue{Value: "REQUIRED_VAL"}
-	if err.Error() != errMissing.Error() {
+	var errMissing *ErrMissingRequiredValue
+	if !errors.As(err, &errMissing) {
 		t.Errorf("Expected error 'ErrMissingRequiredValue' but got '%s'", err)
+	} else if errMissing.Value != "REQUIRED_VAL" {
+		t.Errorf("Expected missing value to be '%s' but got '%s'", "REQUIRED_VAL", errMissing.Value)
 	}
 
 	// Fill REQUIRED_VAL and retry REQUIRED_VAL_MORE
 	environ["REQUIRED_VAL"] = "required"
 	err = Unmarshal(environ, &requiredValuesStruct)
-	if err == nil {
-		t.Errorf("Expected error 'ErrMissingRequiredValue' but got '%s'", err)
-	}
-	errMissing = ErrMissingRequiredValue{Value: "REQUIRED_VAL_MORE"}
-	if err.Error() != errMissing.Error() {
+	if !errors.As(err, &errMissing) {
 		t.Errorf("Expected error 'ErrMissingRequiredValue' but got '%s'", err)
+	} else if errMissing.Value != "REQUIRED_VAL_MORE" {
+		t.Errorf("Expected missing value to be '%s' but got '%s'", "REQUIRED_VAL_MORE", errMissing.Value)
 	}
 
+	// REQUIRED_VAL was consumed by the previous call
+	environ["REQUIRED_VAL"] = "required"
 	environ["REQUIRED_VAL_MORE"] = "required"
 	if err = Unmarshal(environ, &requiredValuesStruct); err != nil {
 		t.Errorf("Expected no error but got '%s'", err)
@@ -673,3 +678,792 @@ func TestMarshalCustom(t *testing.T) {
 		t.Errorf("Expected field value to be '%s' but got '%s'", `{"someField":43}`, v)
 	}
 }
+
+type FieldErrorStruct struct {
+	Jenkins struct {
+		BuildNumber int `env:"BUILD_NUMBER,build_number"`
+	}
+}
+
+func TestUnmarshalFieldError(t *testing.T) {
+	t.Parallel()
+	var (
+		environ          = map[string]string{"build_number": "abc"}
+		fieldErrorStruct FieldErrorStruct
+	)
+
+	err := Unmarshal(environ, &fieldErrorStruct)
+
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) {
+		t.Fatalf("Expected error 'FieldError' but got '%v'", err)
+	}
+
+	if fieldErr.Field != "Jenkins.BuildNumber" {
+		t.Errorf("Expected field path to be '%s' but got '%s'", "Jenkins.BuildNumber", fieldErr.Field)
+	}
+
+	if !reflect.DeepEqual(fieldErr.Keys, []string{"BUILD_NUMBER", "build_number"}) {
+		t.Errorf("Expected keys to be '%v' but got '%v'", []string{"BUILD_NUMBER", "build_number"}, fieldErr.Keys)
+	}
+
+	if fieldErr.Type != reflect.TypeOf(0) {
+		t.Errorf("Expected type to be '%s' but got '%s'", reflect.TypeOf(0), fieldErr.Type)
+	}
+
+	if fieldErr.Value != "abc" {
+		t.Errorf("Expected value to be '%s' but got '%s'", "abc", fieldErr.Value)
+	}
+
+	var numErr *strconv.NumError
+	if !errors.As(err, &numErr) {
+		t.Errorf("Expected error '*strconv.NumError' but got '%s'", err)
+	}
+
+	fieldErr.Redact()
+	if strings.Contains(fieldErr.Error(), "abc") {
+		t.Errorf("Expected redacted error to not contain '%s' but got '%s'", "abc", fieldErr)
+	}
+
+	if fieldErr.Value != redactedValue {
+		t.Errorf("Expected value to be '%s' but got '%s'", redactedValue, fieldErr.Value)
+	}
+
+	if !errors.As(err, &numErr) {
+		t.Errorf("Expected redacted error to still match '*strconv.NumError' but got '%s'", err)
+	}
+}
+
+func TestUnmarshalUnsupportedFieldError(t *testing.T) {
+	t.Parallel()
+	var (
+		environ           = map[string]string{"COMPLEX": "1+2i"}
+		unsupportedStruct UnsupportedStruct
+	)
+
+	err := Unmarshal(environ, &unsupportedStruct)
+
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) {
+		t.Fatalf("Expected error 'FieldError' but got '%v'", err)
+	}
+
+	if fieldErr.Field != "Complex" {
+		t.Errorf("Expected field path to be '%s' but got '%s'", "Complex", fieldErr.Field)
+	}
+}
+
+type FailingMarshaler struct{}
+
+func (FailingMarshaler) MarshalEnvironmentValue() (string, error) {
+	return "", errors.New("cannot marshal")
+}
+
+func TestMarshalFieldError(t *testing.T) {
+	t.Parallel()
+	failingStruct := struct {
+		Nested struct {
+			Failing FailingMarshaler `env:"FAILING,default=value"`
+		}
+	}{}
+
+	_, err := Marshal(&failingStruct)
+
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) {
+		t.Fatalf("Expected error 'FieldError' but got '%v'", err)
+	}
+
+	if fieldErr.Field != "Nested.Failing" {
+		t.Errorf("Expected field path to be '%s' but got '%s'", "Nested.Failing", fieldErr.Field)
+	}
+
+	if !reflect.DeepEqual(fieldErr.Keys, []string{"FAILING"}) {
+		t.Errorf("Expected keys to be '%v' but got '%v'", []string{"FAILING"}, fieldErr.Keys)
+	}
+}
+
+type DatabaseConfig struct {
+	Host string `env:"HOST"`
+	Port int    `env:"PORT,default=5432"`
+	Auth struct {
+		User string `env:"USER"`
+	} `envPrefix:"AUTH_"`
+}
+
+type PrefixStruct struct {
+	Primary DatabaseConfig `envPrefix:"PRIMARY_DB_"`
+	Replica DatabaseConfig `envPrefix:"REPLICA_DB_"`
+}
+
+func TestUnmarshalPrefix(t *testing.T) {
+	t.Parallel()
+	var (
+		environ = map[string]string{
+			"PRIMARY_DB_HOST":      "primary",
+			"PRIMARY_DB_PORT":      "1234",
+			"PRIMARY_DB_AUTH_USER": "admin",
+			"REPLICA_DB_HOST":      "replica",
+			"HOST":                 "unprefixed",
+		}
+		prefixStruct PrefixStruct
+	)
+
+	if err := Unmarshal(environ, &prefixStruct); err != nil {
+		t.Errorf("Expected no error but got '%s'", err)
+	}
+
+	testCases := [][]interface{}{
+		{prefixStruct.Primary.Host, "primary"},
+		{prefixStruct.Primary.Port, 1234},
+		{prefixStruct.Primary.Auth.User, "admin"},
+		{prefixStruct.Replica.Host, "replica"},
+		{prefixStruct.Replica.Port, 5432},
+		{prefixStruct.Replica.Auth.User, ""},
+	}
+	for _, testCase := range testCases {
+		if !reflect.DeepEqual(testCase[0], testCase[1]) {
+			t.Errorf("Expected field value to be '%v' but got '%v'", testCase[1], testCase[0])
+		}
+	}
+
+	if v, ok := environ["PRIMARY_DB_HOST"]; ok {
+		t.Errorf("Expected field '%s' to not exist but got '%s'", "PRIMARY_DB_HOST", v)
+	}
+
+	if _, ok := environ["HOST"]; !ok {
+		t.Errorf("Expected field '%s' to exist but missing", "HOST")
+	}
+}
+
+func TestMarshalPrefix(t *testing.T) {
+	t.Parallel()
+	var prefixStruct PrefixStruct
+	prefixStruct.Primary.Host = "primary"
+	prefixStruct.Primary.Auth.User = "admin"
+	prefixStruct.Replica.Port = 1234
+
+	es, err := Marshal(&prefixStruct)
+	if err != nil {
+		t.Errorf("Expected no error but got '%s'", err)
+	}
+
+	expected := EnvSet{
+		"PRIMARY_DB_HOST":      "primary",
+		"PRIMARY_DB_PORT":      "0",
+		"PRIMARY_DB_AUTH_USER": "admin",
+		"REPLICA_DB_HOST":      "",
+		"REPLICA_DB_PORT":      "1234",
+		"REPLICA_DB_AUTH_USER": "",
+	}
+	if !reflect.DeepEqual(es, expected) {
+		t.Errorf("Expected EnvSet to be '%v' but got '%v'", expected, es)
+	}
+
+	var roundTrip PrefixStruct
+	if err := Unmarshal(es, &roundTrip); err != nil {
+		t.Errorf("Expected no error but got '%s'", err)
+	}
+
+	if !reflect.DeepEqual(roundTrip, prefixStruct) {
+		t.Errorf("Expected struct to be '%v' but got '%v'", prefixStruct, roundTrip)
+	}
+}
+
+type MapStruct struct {
+	Tags          map[string]string              `env:"TAGS"`
+	WithSeparator map[string]int                 `env:"WITH_SEPARATOR,separator=;,kvseparator=="`
+	Durations     map[int]time.Duration          `env:"DURATIONS"`
+	Custom        map[string]Base64EncodedString `env:"CUSTOM"`
+	Pointer       *map[string]bool               `env:"POINTER"`
+	Empty         map[string]string              `env:"EMPTY"`
+}
+
+func TestUnmarshalMap(t *testing.T) {
+	t.Parallel()
+	var (
+		environ = map[string]string{
+			"TAGS":           "team:core,env:prod,url:http://localhost",
+			"WITH_SEPARATOR": "a=1;b=2",
+			"DURATIONS":      "1:5s,2:1m",
+			"CUSTOM":         "key:" + base64.StdEncoding.EncodeToString([]byte("value")),
+			"POINTER":        "on:true",
+			"EMPTY":          "",
+		}
+		mapStruct MapStruct
+	)
+
+	if err := Unmarshal(environ, &mapStruct); err != nil {
+		t.Errorf("Expected no error but got '%s'", err)
+	}
+
+	testCases := [][]interface{}{
+		{mapStruct.Tags, map[string]string{"team": "core", "env": "prod", "url": "http://localhost"}},
+		{mapStruct.WithSeparator, map[string]int{"a": 1, "b": 2}},
+		{mapStruct.Durations, map[int]time.Duration{1: 5 * time.Second, 2: time.Minute}},
+		{mapStruct.Custom, map[string]Base64EncodedString{"key": "value"}},
+		{mapStruct.Pointer, &map[string]bool{"on": true}},
+		{mapStruct.Empty, map[string]string{}},
+	}
+	for _, testCase := range testCases {
+		if !reflect.DeepEqual(testCase[0], testCase[1]) {
+			t.Errorf("Expected field value to be '%v' but got '%v'", testCase[1], testCase[0])
+		}
+	}
+}
+
+func TestUnmarshalMapInvalid(t *testing.T) {
+	t.Parallel()
+	var (
+		environ   = map[string]string{"DURATIONS": "1:5s,two:1m"}
+		mapStruct MapStruct
+	)
+
+	var numErr *strconv.NumError
+	if err := Unmarshal(environ, &mapStruct); !errors.As(err, &numErr) {
+		t.Errorf("Expected error '*strconv.NumError' but got '%v'", err)
+	}
+
+	environ = map[string]string{"TAGS": "team:core,prod"}
+	if err := Unmarshal(environ, &mapStruct); err == nil {
+		t.Errorf("Expected an error for an entry without separator but got none")
+	}
+}
+
+func TestMarshalMap(t *testing.T) {
+	t.Parallel()
+	mapStruct := MapStruct{
+		Tags:          map[string]string{"team": "core", "env": "prod", "a1": "x", "a": "y"},
+		WithSeparator: map[string]int{"b": 2, "a": 1},
+		Durations:     map[int]time.Duration{2: time.Minute, 1: 5 * time.Second},
+		Custom:        map[string]Base64EncodedString{"key": "value"},
+	}
+
+	es, err := Marshal(&mapStruct)
+	if err != nil {
+		t.Errorf("Expected no error but got '%s'", err)
+	}
+
+	expected := EnvSet{
+		"TAGS":           "a:y,a1:x,env:prod,team:core",
+		"WITH_SEPARATOR": "a=1;b=2",
+		"DURATIONS":      "1:5s,2:1m0s",
+		"CUSTOM":         "key:" + base64.StdEncoding.EncodeToString([]byte("value")),
+		"EMPTY":          "",
+	}
+	if !reflect.DeepEqual(es, expected) {
+		t.Errorf("Expected EnvSet to be '%v' but got '%v'", expected, es)
+	}
+
+	var roundTrip MapStruct
+	if err := Unmarshal(es, &roundTrip); err != nil {
+		t.Errorf("Expected no error but got '%s'", err)
+	}
+
+	if !reflect.DeepEqual(roundTrip.Tags, mapStruct.Tags) {
+		t.Errorf("Expected field value to be '%v' but got '%v'", mapStruct.Tags, roundTrip.Tags)
+	}
+}
+
+type PrefixedMapStruct struct {
+	Flags    map[string]bool   `env:"FEATURE_FLAG_*"`
+	Labels   map[string]string `env:"LABEL_*,TAG_*"`
+	Services struct {
+		Timeouts map[string]time.Duration `env:"TIMEOUT_*,required=true"`
+	} `envPrefix:"SERVICE_"`
+}
+
+func TestUnmarshalPrefixedMap(t *testing.T) {
+	t.Parallel()
+	var (
+		environ = map[string]string{
+			"FEATURE_FLAG_BETA":    "true",
+			"FEATURE_FLAG_LEGACY":  "false",
+			"LABEL_team":           "core",
+			"TAG_team":             "ignored",
+			"TAG_env":              "prod",
+			"SERVICE_TIMEOUT_auth": "5s",
+			"FEATURE_FLAG_":        "true",
+			"OTHER":                "other",
+		}
+		prefixedMapStruct PrefixedMapStruct
+	)
+
+	if err := Unmarshal(environ, &prefixedMapStruct); err != nil {
+		t.Errorf("Expected no error but got '%s'", err)
+	}
+
+	testCases := [][]interface{}{
+		{prefixedMapStruct.Flags, map[string]bool{"BETA": true, "LEGACY": false}},
+		{prefixedMapStruct.Labels, map[string]string{"team": "core", "env": "prod"}},
+		{prefixedMapStruct.Services.Timeouts, map[string]time.Duration{"auth": 5 * time.Second}},
+	}
+	for _, testCase := range testCases {
+		if !reflect.DeepEqual(testCase[0], testCase[1]) {
+			t.Errorf("Expected field value to be '%v' but got '%v'", testCase[1], testCase[0])
+		}
+	}
+
+	expected := EnvSet{"TAG_team": "ignored", "FEATURE_FLAG_": "true", "OTHER": "other"}
+	if !reflect.DeepEqual(EnvSet(environ), expected) {
+		t.Errorf("Expected remaining EnvSet to be '%v' but got '%v'", expected, environ)
+	}
+}
+
+type ClaimedPrefixStruct struct {
+	Flags   map[string]bool   `env:"FF_*"`
+	Default bool              `env:"FF_DEFAULT"`
+	Apps    map[string]string `env:"APP_*"`
+	Server  struct {
+		Port int `env:"PORT"`
+	} `envPrefix:"APP_"`
+	Workers []struct {
+		Name string `env:"NAME"`
+	} `envPrefix:"APP_WORKER_"`
+	Nested struct {
+		Labels map[string]string `env:"LABEL_*"`
+		Team   string            `env:"LABEL_TEAM"`
+	} `envPrefix:"NESTED_"`
+}
+
+func TestUnmarshalPrefixedMapClaimedKeys(t *testing.T) {
+	t.Parallel()
+	var (
+		environ = map[string]string{
+			"FF_BETA":           "true",
+			"FF_DEFAULT":        "true",
+			"APP_X":             "y",
+			"APP_PORT":          "80",
+			"APP_WORKER_0_NAME": "a",
+			"APP_WORKER_1_NAME": "b",
+			"NESTED_LABEL_env":  "prod",
+			"NESTED_LABEL_TEAM": "core",
+		}
+		claimedPrefixStruct ClaimedPrefixStruct
+	)
+
+	if err := Unmarshal(environ, &claimedPrefixStruct); err != nil {
+		t.Errorf("Expected no error but got '%s'", err)
+	}
+
+	testCases := [][]interface{}{
+		{claimedPrefixStruct.Flags, map[string]bool{"BETA": true}},
+		{claimedPrefixStruct.Default, true},
+		{claimedPrefixStruct.Apps, map[string]string{"X": "y"}},
+		{claimedPrefixStruct.Server.Port, 80},
+		{len(claimedPrefixStruct.Workers), 2},
+		{claimedPrefixStruct.Nested.Labels, map[string]string{"env": "prod"}},
+		{claimedPrefixStruct.Nested.Team, "core"},
+	}
+	for _, testCase := range testCases {
+		if !reflect.DeepEqual(testCase[0], testCase[1]) {
+			t.Errorf("Expected field value to be '%v' but got '%v'", testCase[1], testCase[0])
+		}
+	}
+}
+
+func TestUnmarshalPrefixedMapErrors(t *testing.T) {
+	t.Parallel()
+	var (
+		environ           = map[string]string{"FEATURE_FLAG_BETA": "maybe"}
+		prefixedMapStruct PrefixedMapStruct
+	)
+
+	err := NewDecoder(WithAllErrors()).Unmarshal(environ, &prefixedMapStruct)
+
+	var numErr *strconv.NumError
+	if !errors.As(err, &numErr) {
+		t.Errorf("Expected error '*strconv.NumError' but got '%v'", err)
+	}
+
+	var errMissing *ErrMissingRequiredValue
+	if !errors.As(err, &errMissing) {
+		t.Errorf("Expected error 'ErrMissingRequiredValue' but got '%v'", err)
+	} else if errMissing.Value != "SERVICE_TIMEOUT_*" {
+		t.Errorf("Expected missing value to be '%s' but got '%s'", "SERVICE_TIMEOUT_*", errMissing.Value)
+	}
+
+	if _, ok := environ["FEATURE_FLAG_BETA"]; !ok {
+		t.Errorf("Expected field '%s' to exist but missing", "FEATURE_FLAG_BETA")
+	}
+
+	unsupportedStruct := struct {
+		Flags map[int]bool `env:"FLAG_*"`
+	}{}
+	if err := Unmarshal(map[string]string{"FLAG_1": "true"}, &unsupportedStruct); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("Expected error 'ErrUnsupportedType' but got '%v'", err)
+	}
+}
+
+func TestMarshalPrefixedMap(t *testing.T) {
+	t.Parallel()
+	var prefixedMapStruct PrefixedMapStruct
+	prefixedMapStruct.Flags = map[string]bool{"BETA": true}
+	prefixedMapStruct.Labels = map[string]string{"team": "core"}
+	prefixedMapStruct.Services.Timeouts = map[string]time.Duration{"auth": 5 * time.Second}
+
+	es, err := Marshal(&prefixedMapStruct)
+	if err != nil {
+		t.Errorf("Expected no error but got '%s'", err)
+	}
+
+	expected := EnvSet{
+		"FEATURE_FLAG_BETA":    "true",
+		"LABEL_team":           "core",
+		"TAG_team":             "core",
+		"SERVICE_TIMEOUT_auth": "5s",
+	}
+	if !reflect.DeepEqual(es, expected) {
+		t.Errorf("Expected EnvSet to be '%v' but got '%v'", expected, es)
+	}
+}
+
+type Upstream struct {
+	Host string `env:"HOST"`
+	Port int    `env:"PORT,required=true"`
+}
+
+type StructSliceStruct struct {
+	Upstreams        []Upstream  `envPrefix:"UPSTREAM_"`
+	PointerUpstreams []*Upstream `envPrefix:"POINTER_UPSTREAM_"`
+	Missing          []Upstream  `envPrefix:"MISSING_UPSTREAM_"`
+}
+
+func TestUnmarshalStructSlice(t *testing.T) {
+	t.Parallel()
+	var (
+		environ = map[string]string{
+			"UPSTREAM_0_HOST":         "first",
+			"UPSTREAM_0_PORT":         "80",
+			"UPSTREAM_1_HOST":         "second",
+			"UPSTREAM_1_PORT":         "8080",
+			"UPSTREAM_3_HOST":         "unreachable",
+			"UPSTREAM_3_PORT":         "443",
+			"POINTER_UPSTREAM_0_PORT": "443",
+		}
+		structSliceStruct StructSliceStruct
+	)
+
+	if err := Unmarshal(environ, &structSliceStruct); err != nil {
+		t.Errorf("Expected no error but got '%s'", err)
+	}
+
+	testCases := [][]interface{}{
+		{structSliceStruct.Upstreams, []Upstream{{Host: "first", Port: 80}, {Host: "second", Port: 8080}}},
+		{structSliceStruct.PointerUpstreams, []*Upstream{{Port: 443}}},
+		{structSliceStruct.Missing, []Upstream(nil)},
+	}
+	for _, testCase := range testCases {
+		if !reflect.DeepEqual(testCase[0], testCase[1]) {
+			t.Errorf("Expected field value to be '%v' but got '%v'", testCase[1], testCase[0])
+		}
+	}
+
+	if _, ok := environ["UPSTREAM_3_HOST"]; !ok {
+		t.Errorf("Expected field '%s' to exist but missing", "UPSTREAM_3_HOST")
+	}
+}
+
+func TestUnmarshalStructSliceError(t *testing.T) {
+	t.Parallel()
+	var (
+		environ           = map[string]string{"UPSTREAM_0_PORT": "80", "UPSTREAM_1_HOST": "second"}
+		structSliceStruct StructSliceStruct
+	)
+
+	err := Unmarshal(environ, &structSliceStruct)
+
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) {
+		t.Fatalf("Expected error 'FieldError' but got '%v'", err)
+	}
+
+	if fieldErr.Field != "Upstreams[1].Port" {
+		t.Errorf("Expected field path to be '%s' but got '%s'", "Upstreams[1].Port", fieldErr.Field)
+	}
+
+	if !reflect.DeepEqual(fieldErr.Keys, []string{"UPSTREAM_1_PORT"}) {
+		t.Errorf("Expected keys to be '%v' but got '%v'", []string{"UPSTREAM_1_PORT"}, fieldErr.Keys)
+	}
+}
+
+func TestMarshalStructSlice(t *testing.T) {
+	t.Parallel()
+	structSliceStruct := StructSliceStruct{
+		Upstreams:        []Upstream{{Host: "first", Port: 80}, {Host: "second", Port: 8080}},
+		PointerUpstreams: []*Upstream{nil, {Host: "third", Port: 443}},
+	}
+
+	es, err := Marshal(&structSliceStruct)
+	if err != nil {
+		t.Errorf("Expected no error but got '%s'", err)
+	}
+
+	expected := EnvSet{
+		"UPSTREAM_0_HOST":         "first",
+		"UPSTREAM_0_PORT":         "80",
+		"UPSTREAM_1_HOST":         "second",
+		"UPSTREAM_1_PORT":         "8080",
+		"POINTER_UPSTREAM_0_HOST": "",
+		"POINTER_UPSTREAM_0_PORT": "0",
+		"POINTER_UPSTREAM_1_HOST": "third",
+		"POINTER_UPSTREAM_1_PORT": "443",
+	}
+	if !reflect.DeepEqual(es, expected) {
+		t.Errorf("Expected EnvSet to be '%v' but got '%v'", expected, es)
+	}
+
+	var roundTrip StructSliceStruct
+	if err := Unmarshal(es, &roundTrip); err != nil {
+		t.Errorf("Expected no error but got '%s'", err)
+	}
+
+	if !reflect.DeepEqual(roundTrip.Upstreams, structSliceStruct.Upstreams) {
+		t.Errorf("Expected field value to be '%v' but got '%v'", structSliceStruct.Upstreams, roundTrip.Upstreams)
+	}
+}
+
+type TextStruct struct {
+	IP          net.IP       `env:"IP"`
+	Addr        netip.Addr   `env:"ADDR"`
+	PointerAddr *netip.Addr  `env:"POINTER_ADDR"`
+	Addrs       []netip.Addr `env:"ADDRS,separator=;"`
+	BigInt      *big.Int     `env:"BIG_INT"`
+	Level       slog.Level   `env:"LEVEL"`
+	Timestamp   time.Time    `env:"TIMESTAMP"`
+	// Both is an Unmarshaler as well as an encoding.TextUnmarshaler
+	Both      Both   `env:"BOTH"`
+	BothSlice []Both `env:"BOTH_SLICE"`
+}
+
+type Both string
+
+func (b *Both) UnmarshalEnvironmentValue(data string) error {
+	*b = Both("env:" + data)
+	return nil
+}
+
+func (b *Both) UnmarshalText(text []byte) error {
+	*b = Both("text:" + string(text))
+	return nil
+}
+
+func (b Both) MarshalEnvironmentValue() (string, error) {
+	return strings.TrimPrefix(string(b), "env:"), nil
+}
+
+func (b Both) MarshalText() ([]byte, error) {
+	return []byte("text"), nil
+}
+
+func TestUnmarshalText(t *testing.T) {
+	t.Parallel()
+	var (
+		environ = map[string]string{
+			"IP":           "10.0.0.1",
+			"ADDR":         "10.0.0.2",
+			"POINTER_ADDR": "::1",
+			"ADDRS":        "10.0.0.3;10.0.0.4",
+			"BIG_INT":      "123456789012345678901234567890",
+			"LEVEL":        "WARN",
+			"TIMESTAMP":    "2016-07-15T12:00:00Z",
+			"BOTH":         "value",
+			"BOTH_SLICE":   "first|second",
+		}
+		textStruct TextStruct
+	)
+
+	if err := Unmarshal(environ, &textStruct); err != nil {
+		t.Errorf("Expected no error but got '%s'", err)
+	}
+
+	bigInt, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	pointerAddr := netip.MustParseAddr("::1")
+	testCases := [][]interface{}{
+		{textStruct.IP, net.ParseIP("10.0.0.1")},
+		{textStruct.Addr, netip.MustParseAddr("10.0.0.2")},
+		{textStruct.PointerAddr, &pointerAddr},
+		{textStruct.Addrs, []netip.Addr{netip.MustParseAddr("10.0.0.3"), netip.MustParseAddr("10.0.0.4")}},
+		{textStruct.BigInt, bigInt},
+		{textStruct.Level, slog.LevelWarn},
+		{textStruct.Timestamp, time.Date(2016, 7, 15, 12, 0, 0, 0, time.UTC)},
+		{textStruct.Both, Both("env:value")},
+		{textStruct.BothSlice, []Both{"env:first", "env:second"}},
+	}
+	for _, testCase := range testCases {
+		if !reflect.DeepEqual(testCase[0], testCase[1]) {
+			t.Errorf("Expected field value to be '%v' but got '%v'", testCase[1], testCase[0])
+		}
+	}
+
+	environ = map[string]string{"ADDR": "not an address"}
+	var fieldErr *FieldError
+	if err := Unmarshal(environ, &textStruct); !errors.As(err, &fieldErr) {
+		t.Errorf("Expected error 'FieldError' but got '%v'", err)
+	} else if fieldErr.Field != "Addr" {
+		t.Errorf("Expected field path to be '%s' but got '%s'", "Addr", fieldErr.Field)
+	}
+}
+
+func TestMarshalText(t *testing.T) {
+	t.Parallel()
+	var (
+		bigInt, _   = new(big.Int).SetString("123456789012345678901234567890", 10)
+		pointerAddr = netip.MustParseAddr("::1")
+		textStruct  = TextStruct{
+			IP:          net.ParseIP("10.0.0.1"),
+			Addr:        netip.MustParseAddr("10.0.0.2"),
+			PointerAddr: &pointerAddr,
+			Addrs:       []netip.Addr{netip.MustParseAddr("10.0.0.3"), netip.MustParseAddr("10.0.0.4")},
+			BigInt:      bigInt,
+			Level:       slog.LevelWarn,
+			Timestamp:   time.Date(2016, 7, 15, 12, 0, 0, 0, time.UTC),
+			Both:        Both("value"),
+			BothSlice:   []Both{"first", "second"},
+		}
+	)
+
+	es, err := Marshal(&textStruct)
+	if err != nil {
+		t.Errorf("Expected no error but got '%s'", err)
+	}
+
+	expected := EnvSet{
+		"IP":           "10.0.0.1",
+		"ADDR":         "10.0.0.2",
+		"POINTER_ADDR": "::1",
+		"ADDRS":        "10.0.0.3;10.0.0.4",
+		"BIG_INT":      "123456789012345678901234567890",
+		"LEVEL":        "WARN",
+		"TIMESTAMP":    "2016-07-15T12:00:00Z",
+		"BOTH":         "value",
+		"BOTH_SLICE":   "first|second",
+	}
+	if !reflect.DeepEqual(es, expected) {
+		t.Errorf("Expected EnvSet to be '%v' but got '%v'", expected, es)
+	}
+}
+
+type QuotedTagStruct struct {
+	Commas    string            `env:"COMMAS,default='a,b'"`
+	Escaped   string            `env:"ESCAPED,default=a\\,b\\\\c\\d"`
+	JSON      JSONData          `env:"JSON,default='{\"someField\":42}'"`
+	Slice     []string          `env:"SLICE,separator=','"`
+	Map       map[string]string `env:"MAP,separator=;,kvseparator=\\,"`
+	Quote     string            `env:"QUOTE,default=it\\'s"`
+	Literal   string            `env:"LITERAL,default=don't panic"`
+	QuotedKey string            `env:"'QUOTED,KEY',required=true"`
+}
+
+func TestUnmarshalQuotedTag(t *testing.T) {
+	t.Parallel()
+	var (
+		environ = map[string]string{
+			"SLICE":      "a,b",
+			"MAP":        "a,1;b,2",
+			"QUOTED,KEY": "quoted",
+		}
+		quotedTagStruct QuotedTagStruct
+	)
+
+	if err := Unmarshal(environ, &quotedTagStruct); err != nil {
+		t.Errorf("Expected no error but got '%s'", err)
+	}
+
+	testCases := [][]interface{}{
+		{quotedTagStruct.Commas, "a,b"},
+		{quotedTagStruct.Escaped, `a,b\c\d`},
+		{quotedTagStruct.JSON, JSONData{SomeField: 42}},
+		{quotedTagStruct.Slice, []string{"a", "b"}},
+		{quotedTagStruct.Map, map[string]string{"a": "1", "b": "2"}},
+		{quotedTagStruct.Quote, "it's"},
+		{quotedTagStruct.Literal, "don't panic"},
+		{quotedTagStruct.QuotedKey, "quoted"},
+	}
+	for _, testCase := range testCases {
+		if !reflect.DeepEqual(testCase[0], testCase[1]) {
+			t.Errorf("Expected field value to be '%v' but got '%v'", testCase[1], testCase[0])
+		}
+	}
+}
+
+func TestMarshalQuotedTag(t *testing.T) {
+	t.Parallel()
+	quotedTagStruct := QuotedTagStruct{Commas: "c", QuotedKey: "quoted"}
+
+	es, err := Marshal(&quotedTagStruct)
+	if err != nil {
+		t.Errorf("Expected no error but got '%s'", err)
+	}
+
+	for _, key := range []string{"COMMAS", "ESCAPED", "JSON", "SLICE", "MAP", "QUOTE", "LITERAL", "QUOTED,KEY"} {
+		if _, ok := es[key]; !ok {
+			t.Errorf("Expected field '%s' to exist but missing", key)
+		}
+	}
+
+	if len(es) != 8 {
+		t.Errorf("Expected EnvSet to have %d items but got '%v'", 8, es)
+	}
+}
+
+func TestParseTagBareOptions(t *testing.T) {
+	t.Parallel()
+	envTag, err := parseTag("NAME,required,notempty,file,secret")
+	if err != nil {
+		t.Errorf("Expected no error but got '%s'", err)
+	}
+	if !reflect.DeepEqual(envTag.Keys, []string{"NAME"}) || !envTag.Required || !envTag.NotEmpty || !envTag.File || !envTag.Secret {
+		t.Errorf("Expected bare options to be set but got '%+v'", envTag)
+	}
+
+	testCases := []struct {
+		tag  string
+		keys []string
+	}{
+		{"REQUIRED,NAME", []string{"REQUIRED", "NAME"}},
+		{"HOME,FILE", []string{"HOME", "FILE"}},
+		{"TOKEN,REQUIRED,SECRET,NotEmpty", []string{"TOKEN", "REQUIRED", "SECRET", "NotEmpty"}},
+	}
+	for _, testCase := range testCases {
+		envTag, err := parseTag(testCase.tag)
+		if err != nil {
+			t.Errorf("Expected no error but got '%s'", err)
+		}
+		if !reflect.DeepEqual(envTag.Keys, testCase.keys) || envTag.Required || envTag.File || envTag.NotEmpty || envTag.Secret {
+			t.Errorf("Expected keys to be '%v' and no options but got '%+v'", testCase.keys, envTag)
+		}
+	}
+}
+
+func TestParseTagInvalid(t *testing.T) {
+	t.Parallel()
+	for _, tagString := range []string{
+		"KEY,default='unterminated",
+		"KEY,,required=true",
+		"KEY,",
+		"KEY,=value",
+		"default=value",
+	} {
+		if _, err := parseTag(tagString); !errors.Is(err, ErrInvalidTag) {
+			t.Errorf("Expected error 'ErrInvalidTag' for '%s' but got '%v'", tagString, err)
+		}
+	}
+
+	invalidTagStruct := struct {
+		Invalid string `env:"INVALID,default='a,b"`
+	}{}
+	var fieldErr *FieldError
+	if err := Unmarshal(map[string]string{}, &invalidTagStruct); !errors.As(err, &fieldErr) || !errors.Is(err, ErrInvalidTag) {
+		t.Errorf("Expected error 'ErrInvalidTag' but got '%v'", err)
+	} else if fieldErr.Field != "Invalid" {
+		t.Errorf("Expected field path to be '%s' but got '%s'", "Invalid", fieldErr.Field)
+	}
+	if expected := `env: field "Invalid" (string): invalid field tag: unterminated quote in "INVALID,default='a,b"`; fieldErr.Error() != expected {
+		t.Errorf("Expected error to be '%s' but got '%s'", expected, fieldErr)
+	}
+
+	if _, err := Marshal(&invalidTagStruct); !errors.Is(err, ErrInvalidTag) {
+		t.Errorf("Expected error 'ErrInvalidTag' but got '%v'", err)
+	}
+}
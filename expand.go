@@ -0,0 +1,193 @@
+// Copyright 2018 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package env
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrExpansionCycle returned when expanding a value refers back to a
+	// variable being expanded.
+	ErrExpansionCycle = errors.New("variable expansion cycle")
+
+	// ErrInvalidExpansion returned when a value has a malformed "${" reference.
+	ErrInvalidExpansion = errors.New("invalid variable reference")
+)
+
+// WithExpansion makes the Decoder expand references to other variables in
+// values and defaults, against the EnvSet being unmarshalled:
+//
+//   - $VAR and ${VAR} are replaced with the value of VAR, or with nothing if
+//     VAR is not set,
+//   - ${VAR:-fallback} is replaced with the value of VAR, or with fallback if
+//     VAR is not set or empty,
+//   - $$ is replaced with a literal $.
+//
+// Referenced values are expanded as well. References that lead back to a
+// variable being expanded return an error wrapping ErrExpansionCycle, which
+// reports the chain of variables.
+func WithExpansion() Option {
+	return func(c *config) {
+		c.expand = true
+	}
+}
+
+// expander expands references to the variables of an EnvSet.
+type expander struct {
+	es EnvSet
+	// expanded caches the expanded value of variables
+	expanded map[string]string
+	// chain holds the variables being expanded, to detect cycles
+	chain []string
+}
+
+func newExpander(es EnvSet) *expander {
+	// Copy the EnvSet, as Unmarshal deletes the variables it uses while
+	// references to them may still come up
+	snapshot := make(EnvSet, len(es))
+	for k, v := range es {
+		snapshot[k] = v
+	}
+	return &expander{es: snapshot, expanded: make(map[string]string)}
+}
+
+// expand returns s with all its references expanded.
+func (x *expander) expand(s string) (string, error) {
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(s, '$')
+		if i < 0 || i == len(s)-1 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+		b.WriteString(s[:i])
+		s = s[i+1:]
+
+		switch {
+		case s[0] == '$':
+			b.WriteByte('$')
+			s = s[1:]
+		case s[0] == '{':
+			end := matchingBrace(s)
+			if end < 0 {
+				return "", fmt.Errorf("%w: unterminated ${ in %q", ErrInvalidExpansion, "$"+s)
+			}
+			name, fallback, hasFallback := strings.Cut(s[1:end], ":-")
+			if !isVariableName(name) {
+				return "", fmt.Errorf("%w: %q", ErrInvalidExpansion, "$"+s[:end+1])
+			}
+			value, err := x.lookup(name)
+			if err != nil {
+				return "", err
+			}
+			if value == "" && hasFallback {
+				if value, err = x.expand(fallback); err != nil {
+					return "", err
+				}
+			}
+			b.WriteString(value)
+			s = s[end+1:]
+		default:
+			n := 0
+			for n < len(s) && isVariableChar(s[n], n == 0) {
+				n++
+			}
+			if n == 0 {
+				// Not a reference, keep the $ as is
+				b.WriteByte('$')
+				continue
+			}
+			value, err := x.lookup(s[:n])
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(value)
+			s = s[n:]
+		}
+	}
+}
+
+// lookup returns the expanded value of the variable name, or an empty string
+// if it is not set.
+func (x *expander) lookup(name string) (string, error) {
+	if value, ok := x.expanded[name]; ok {
+		return value, nil
+	}
+	for i, n := range x.chain {
+		if n == name {
+			chain := append(append([]string{}, x.chain[i:]...), name)
+			return "", fmt.Errorf("%w: %s", ErrExpansionCycle, strings.Join(chain, " -> "))
+		}
+	}
+
+	raw, ok := x.es[name]
+	if !ok {
+		return "", nil
+	}
+	x.chain = append(x.chain, name)
+	value, err := x.expand(raw)
+	x.chain = x.chain[:len(x.chain)-1]
+	if err != nil {
+		return "", err
+	}
+	x.expanded[name] = value
+	return value, nil
+}
+
+// matchingBrace returns the index of the "}" closing the "{" s starts with,
+// or -1 if there is none.
+func matchingBrace(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// isVariableName reports whether name can be referenced by ${name}.
+func isVariableName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isVariableChar(name[i], i == 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// isVariableChar reports whether c can be part of a variable name, at its
+// start if first is set.
+func isVariableChar(c byte, first bool) bool {
+	switch {
+	case c == '_', 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		return true
+	case '0' <= c && c <= '9':
+		return !first
+	default:
+		return false
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2018 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package env
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type ExpansionStruct struct {
+	Host    string            `env:"HOST"`
+	URL     string            `env:"URL"`
+	DataDir string            `env:"DATA_DIR,default=${HOME}/.cache/app"`
+	Price   string            `env:"PRICE"`
+	Region  string            `env:"REGION,default=${AWS_REGION:-us-east-1}"`
+	Labels  map[string]string `env:"LABEL_*"`
+}
+
+func TestUnmarshalExpansion(t *testing.T) {
+	t.Parallel()
+	var (
+		environ = map[string]string{
+			"HOST":       "localhost",
+			"PORT":       "8080",
+			"URL":        "http://${HOST}:$PORT/$",
+			"HOME":       "/home/test",
+			"PRICE":      "$$5 ${MISSING}",
+			"LABEL_host": "$HOST",
+		}
+		expansionStruct ExpansionStruct
+	)
+
+	if err := NewDecoder(WithExpansion()).Unmarshal(environ, &expansionStruct); err != nil {
+		t.Errorf("Expected no error but got '%s'", err)
+	}
+
+	testCases := [][]string{
+		{expansionStruct.Host, "localhost"},
+		{expansionStruct.URL, "http://localhost:8080/$"},
+		{expansionStruct.DataDir, "/home/test/.cache/app"},
+		{expansionStruct.Price, "$5 "},
+		{expansionStruct.Region, "us-east-1"},
+		{expansionStruct.Labels["host"], "localhost"},
+	}
+	for _, testCase := range testCases {
+		if testCase[0] != testCase[1] {
+			t.Errorf("Expected field value to be '%s' but got '%s'", testCase[1], testCase[0])
+		}
+	}
+}
+
+func TestUnmarshalWithoutExpansion(t *testing.T) {
+	t.Parallel()
+	var (
+		environ         = map[string]string{"URL": "http://${HOST}"}
+		expansionStruct ExpansionStruct
+	)
+
+	if err := Unmarshal(environ, &expansionStruct); err != nil {
+		t.Errorf("Expected no error but got '%s'", err)
+	}
+
+	if expansionStruct.URL != "http://${HOST}" {
+		t.Errorf("Expected field value to be '%s' but got '%s'", "http://${HOST}", expansionStruct.URL)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	t.Parallel()
+	x := newExpander(EnvSet{
+		"A":     "a",
+		"EMPTY": "",
+		"B":     "${A}b",
+		"NEST":  "${MISSING:-${B:-x}}",
+	})
+
+	testCases := [][]string{
+		{"$A", "a"},
+		{"${A}${B}", "aab"},
+		{"$A-$B", "a-ab"},
+		{"${EMPTY:-fallback}", "fallback"},
+		{"${A:-fallback}", "a"},
+		{"${NEST}", "ab"},
+		{"$$A", "$A"},
+		{"$1 $ $-", "$1 $ $-"},
+		{"no references", "no references"},
+	}
+	for _, testCase := range testCases {
+		expanded, err := x.expand(testCase[0])
+		if err != nil {
+			t.Errorf("Expected no error but got '%s'", err)
+		}
+		if expanded != testCase[1] {
+			t.Errorf("Expected '%s' to expand to '%s' but got '%s'", testCase[0], testCase[1], expanded)
+		}
+	}
+
+	for _, invalid := range []string{"${A", "${}", "${A B}"} {
+		if _, err := x.expand(invalid); !errors.Is(err, ErrInvalidExpansion) {
+			t.Errorf("Expected error 'ErrInvalidExpansion' for '%s' but got '%v'", invalid, err)
+		}
+	}
+}
+
+func TestUnmarshalExpansionCycle(t *testing.T) {
+	t.Parallel()
+	var (
+		environ = map[string]string{
+			"HOST": "${URL}",
+			"URL":  "http://${PORT}",
+			"PORT": "${HOST}",
+		}
+		expansionStruct ExpansionStruct
+	)
+
+	err := NewDecoder(WithExpansion()).Unmarshal(environ, &expansionStruct)
+	if !errors.Is(err, ErrExpansionCycle) {
+		t.Fatalf("Expected error 'ErrExpansionCycle' but got '%v'", err)
+	}
+
+	if !strings.Contains(err.Error(), "HOST -> URL -> PORT -> HOST") {
+		t.Errorf("Expected error to report the chain '%s' but got '%s'", "HOST -> URL -> PORT -> HOST", err)
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2018 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package env
+
+import (
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// fileSuffix is appended to a key to get the key of the variable holding the
+// path of the file to read its value from, with WithFileFallback.
+const fileSuffix = "_FILE"
+
+// WithFileFallback makes the Decoder look for a KEY_FILE variable when none of
+// the keys of a field are set, following the convention used by Docker and
+// Kubernetes secrets. Its value is the path of a file, whose content, without
+// its trailing newline, is used as the value of the field.
+func WithFileFallback() Option {
+	return func(c *config) {
+		c.fileFallback = true
+	}
+}
+
+// WithFS makes the Decoder read files, for fields tagged with "file=true" and
+// with WithFileFallback, from fsys instead of the OS file system. As fs.FS
+// paths cannot be rooted, the leading "/" of absolute paths is removed, so
+// that "/run/secrets/db" is read from "run/secrets/db" in fsys.
+func WithFS(fsys fs.FS) Option {
+	return func(c *config) {
+		c.fsys = fsys
+	}
+}
+
+// readFile returns the content of the file at path, without its trailing
+// newline.
+func (c *config) readFile(path string) (string, error) {
+	var (
+		b   []byte
+		err error
+	)
+	if c.fsys != nil {
+		b, err = fs.ReadFile(c.fsys, strings.TrimPrefix(path, "/"))
+	} else {
+		b, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	content := strings.TrimSuffix(string(b), "\n")
+	return strings.TrimSuffix(content, "\r"), nil
+}
@@ -0,0 +1,136 @@
+// Copyright 2018 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package env
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+type FileStruct struct {
+	Password    string `env:"DB_PASSWORD,file=true"`
+	Token       string `env:"TOKEN,file=true,default=/run/secrets/token"`
+	APIKey      string `env:"API_KEY"`
+	Port        int    `env:"PORT"`
+	NotFallback string `env:"NOT_FALLBACK"`
+}
+
+var testFS = fstest.MapFS{
+	"run/secrets/db":    {Data: []byte("s3cr3t\n")},
+	"run/secrets/token": {Data: []byte("token\r\n")},
+	"run/secrets/api":   {Data: []byte("key\n\n")},
+	"run/secrets/port":  {Data: []byte("8080\n")},
+}
+
+func TestUnmarshalFile(t *testing.T) {
+	t.Parallel()
+	var (
+		environ = map[string]string{
+			"DB_PASSWORD":       "/run/secrets/db",
+			"API_KEY_FILE":      "/run/secrets/api",
+			"PORT_FILE":         "run/secrets/port",
+			"NOT_FALLBACK":      "value",
+			"NOT_FALLBACK_FILE": "/run/secrets/missing",
+		}
+		fileStruct FileStruct
+	)
+
+	if err := NewDecoder(WithFS(testFS), WithFileFallback()).Unmarshal(environ, &fileStruct); err != nil {
+		t.Errorf("Expected no error but got '%s'", err)
+	}
+
+	expected := FileStruct{
+		Password:    "s3cr3t",
+		Token:       "token",
+		APIKey:      "key\n",
+		Port:        8080,
+		NotFallback: "value",
+	}
+	if !reflect.DeepEqual(fileStruct, expected) {
+		t.Errorf("Expected struct to be '%+v' but got '%+v'", expected, fileStruct)
+	}
+
+	if v, ok := environ["API_KEY_FILE"]; ok {
+		t.Errorf("Expected field '%s' to not exist but got '%s'", "API_KEY_FILE", v)
+	}
+}
+
+func TestUnmarshalFileWithoutFallback(t *testing.T) {
+	t.Parallel()
+	var (
+		environ    = map[string]string{"API_KEY_FILE": "/run/secrets/api"}
+		fileStruct FileStruct
+	)
+
+	if err := NewDecoder(WithFS(testFS)).Unmarshal(environ, &fileStruct); err != nil {
+		t.Errorf("Expected no error but got '%s'", err)
+	}
+
+	if fileStruct.APIKey != "" {
+		t.Errorf("Expected field value to be '%s' but got '%s'", "", fileStruct.APIKey)
+	}
+}
+
+func TestUnmarshalFileError(t *testing.T) {
+	t.Parallel()
+	var (
+		environ    = map[string]string{"DB_PASSWORD": "/run/secrets/missing"}
+		fileStruct FileStruct
+	)
+
+	err := NewDecoder(WithFS(testFS)).Unmarshal(environ, &fileStruct)
+
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) {
+		t.Fatalf("Expected error 'FieldError' but got '%v'", err)
+	}
+
+	if fieldErr.Field != "Password" {
+		t.Errorf("Expected field path to be '%s' but got '%s'", "Password", fieldErr.Field)
+	}
+
+	if fieldErr.Value != "/run/secrets/missing" {
+		t.Errorf("Expected value to be '%s' but got '%s'", "/run/secrets/missing", fieldErr.Value)
+	}
+
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected error 'fs.ErrNotExist' but got '%s'", err)
+	}
+}
+
+func TestUnmarshalFileFromOS(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "db")
+	if err := os.WriteFile(path, []byte("s3cr3t\n"), 0o600); err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+
+	var (
+		environ    = map[string]string{"DB_PASSWORD": path, "TOKEN": path}
+		fileStruct FileStruct
+	)
+
+	if err := Unmarshal(environ, &fileStruct); err != nil {
+		t.Errorf("Expected no error but got '%s'", err)
+	}
+
+	if fileStruct.Password != "s3cr3t" {
+		t.Errorf("Expected field value to be '%s' but got '%s'", "s3cr3t", fileStruct.Password)
+	}
+}
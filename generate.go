@@ -0,0 +1,130 @@
+// Copyright 2018 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package env
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// PrintDotenvExample writes to w a commented .env.example file for the
+// environment variables read by Unmarshal for v, a struct or a pointer to a
+// struct. See Decoder.PrintDotenvExample.
+func PrintDotenvExample(w io.Writer, v interface{}) error {
+	return NewDecoder().PrintDotenvExample(w, v)
+}
+
+// PrintDotenvExample writes to w a commented .env.example file for the
+// environment variables read by d for v, a struct or a pointer to a struct.
+// Every field gets a KEY=value line, set to its default, preceded by
+// comments holding its "desc" struct field tag, its Go type, its other keys
+// and whether it is required. Optional fields without default are commented
+// out. Fields are grouped like in PrintUsage, and written in the order they
+// are declared, so the output can be diffed.
+func (d *Decoder) PrintDotenvExample(w io.Writer, v interface{}) error {
+	groups, err := d.config.groupFields(v)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	for _, group := range groups {
+		if group.path != "" {
+			fmt.Fprintf(&b, "\n## %s\n", groupTitle(group))
+		}
+		for _, f := range group.fields {
+			b.WriteString("\n")
+			if f.desc != "" {
+				for _, line := range strings.Split(f.desc, "\n") {
+					b.WriteString(strings.TrimSpace("# "+line) + "\n")
+				}
+			}
+			fmt.Fprintf(&b, "# Type: %s\n", f.typ)
+			if len(f.tag.Keys) > 1 {
+				fmt.Fprintf(&b, "# Aliases: %s\n", strings.Join(f.tag.Keys[1:], ", "))
+			}
+			if f.tag.Required {
+				b.WriteString("# Required\n")
+			}
+
+			if !f.tag.Required && f.tag.Default == "" {
+				b.WriteString("# ")
+			}
+			fmt.Fprintf(&b, "%s=%s\n", f.tag.Keys[0], quoteDotenv(f.tag.Default))
+		}
+	}
+
+	_, err = io.WriteString(w, strings.TrimPrefix(b.String(), "\n"))
+	return err
+}
+
+// PrintMarkdown writes to w a Markdown table of the environment variables read
+// by Unmarshal for v, a struct or a pointer to a struct. See
+// Decoder.PrintMarkdown.
+func PrintMarkdown(w io.Writer, v interface{}) error {
+	return NewDecoder().PrintMarkdown(w, v)
+}
+
+// PrintMarkdown writes to w a Markdown table of the environment variables read
+// by d for v, a struct or a pointer to a struct, with one row per field,
+// grouped like in PrintUsage and in the order they are declared. The columns
+// are the keys, the Go type, the default, whether the field is required, and
+// the "desc" struct field tag.
+func (d *Decoder) PrintMarkdown(w io.Writer, v interface{}) error {
+	groups, err := d.config.groupFields(v)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	b.WriteString("| Key | Type | Default | Required | Description |\n")
+	b.WriteString("| --- | --- | --- | --- | --- |\n")
+	for _, group := range groups {
+		for _, f := range group.fields {
+			keys := make([]string, len(f.tag.Keys))
+			for i, key := range f.tag.Keys {
+				keys[i] = markdownCode(key)
+			}
+			var defaultValue string
+			if f.tag.Default != "" {
+				defaultValue = markdownCode(f.tag.Default)
+			}
+			required := "no"
+			if f.tag.Required {
+				required = "yes"
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s | %s | %s |\n",
+				strings.Join(keys, ", "), markdownCode(f.typ.String()), defaultValue, required, markdownText(f.desc))
+		}
+	}
+
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// markdownCode returns s as inline code that can be used in a table cell.
+func markdownCode(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	if strings.Contains(s, "`") {
+		return "`` " + s + " ``"
+	}
+	return "`" + s + "`"
+}
+
+// markdownText returns s as text that can be used in a table cell.
+func markdownText(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", "<br>")
+}
@@ -0,0 +1,134 @@
+// Copyright 2018 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package env
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPrintDotenvExample(t *testing.T) {
+	t.Parallel()
+	var b strings.Builder
+	if err := PrintDotenvExample(&b, &UsageStruct{}); err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+
+	expected := `# port to listen on
+# Type: int
+# Aliases: HTTP_PORT
+PORT=8080
+
+# home directory
+# of the service
+# Type: string
+# Required
+HOME=
+
+# labels added to metrics
+# Type: map[string]string
+# LABEL_*=
+
+# Type: env.Secret[string]
+# PASSWORD=
+
+## Database: primary database
+
+# database host
+# Type: string
+DB_HOST=localhost
+
+# Type: time.Duration
+# DB_TIMEOUT=
+
+## Upstreams[0]:
+
+# Type: string
+# Required
+UPSTREAM_0_URL=
+`
+	if b.String() != expected {
+		t.Errorf("Expected .env.example to be '%s' but got '%s'", expected, b.String())
+	}
+}
+
+func TestPrintMarkdown(t *testing.T) {
+	t.Parallel()
+	var b strings.Builder
+	if err := PrintMarkdown(&b, &UsageStruct{}); err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+
+	expected := "| Key | Type | Default | Required | Description |\n" +
+		"| --- | --- | --- | --- | --- |\n" +
+		"| `PORT`, `HTTP_PORT` | `int` | `8080` | no | port to listen on |\n" +
+		"| `HOME` | `string` |  | yes | home directory<br>of the service |\n" +
+		"| `LABEL_*` | `map[string]string` |  | no | labels added to metrics |\n" +
+		"| `PASSWORD` | `env.Secret[string]` |  | no |  |\n" +
+		"| `DB_HOST` | `string` | `localhost` | no | database host |\n" +
+		"| `DB_TIMEOUT` | `time.Duration` |  | no |  |\n" +
+		"| `UPSTREAM_0_URL` | `string` |  | yes |  |\n"
+	if b.String() != expected {
+		t.Errorf("Expected Markdown to be '%s' but got '%s'", expected, b.String())
+	}
+}
+
+func TestMarkdownEscaping(t *testing.T) {
+	t.Parallel()
+	testCases := [][]string{
+		{markdownCode("a|b"), "`a\\|b`"},
+		{markdownCode("a`b"), "`` a`b ``"},
+		{markdownText("a|b\nc"), "a\\|b<br>c"},
+	}
+	for _, testCase := range testCases {
+		if testCase[0] != testCase[1] {
+			t.Errorf("Expected '%s' but got '%s'", testCase[1], testCase[0])
+		}
+	}
+}
+
+type TreeNode struct {
+	Name     string     `env:"NAME"`
+	Children []TreeNode `envPrefix:"CHILD_"`
+}
+
+func TestGenerateSelfReferencingStruct(t *testing.T) {
+	t.Parallel()
+	var b strings.Builder
+	if err := PrintUsage(&b, &TreeNode{}); err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+	if expected := "  NAME string\n"; b.String() != expected {
+		t.Errorf("Expected usage to be '%s' but got '%s'", expected, b.String())
+	}
+
+	generators := []func() error{
+		func() error { return PrintDotenvExample(&b, &TreeNode{}) },
+		func() error { return PrintMarkdown(&b, &TreeNode{}) },
+		func() error { _, err := JSONSchema(&TreeNode{}); return err },
+	}
+	for _, generate := range generators {
+		if err := generate(); err != nil {
+			t.Errorf("Expected no error but got '%s'", err)
+		}
+	}
+
+	var treeNode TreeNode
+	if err := Unmarshal(EnvSet{"NAME": "root", "CHILD_0_NAME": "a", "CHILD_0_CHILD_0_NAME": "b"}, &treeNode); err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+	if len(treeNode.Children) != 1 || len(treeNode.Children[0].Children) != 1 || treeNode.Children[0].Children[0].Name != "b" {
+		t.Errorf("Expected nested children to be set but got '%+v'", treeNode)
+	}
+}
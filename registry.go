@@ -0,0 +1,153 @@
+// Copyright 2018 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package env
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// ParseFunc parses an environment variable value into a value of the type it
+// is registered for.
+type ParseFunc func(value string) (interface{}, error)
+
+// FormatFunc formats a value of the type it is registered for into an
+// environment variable value.
+type FormatFunc func(v interface{}) (string, error)
+
+// Registry holds parse and format functions keyed by type, for types that
+// cannot implement Unmarshaler and Marshaler themselves. A Registry is safe
+// for concurrent use, so a single one can be shared across Decoders and
+// Encoders with WithRegistry.
+type Registry struct {
+	mu         sync.RWMutex
+	parsers    map[reflect.Type]ParseFunc
+	formatters map[reflect.Type]FormatFunc
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		parsers:    make(map[reflect.Type]ParseFunc),
+		formatters: make(map[reflect.Type]FormatFunc),
+	}
+}
+
+// Register registers the parse and format functions of t, replacing the
+// previous ones if any. Either of them can be nil to only handle one
+// direction.
+//
+// The functions also apply to slice elements, map keys and values, and the
+// targets of pointers of type t.
+func (r *Registry) Register(t reflect.Type, parse ParseFunc, format FormatFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if parse != nil {
+		r.parsers[t] = parse
+	}
+	if format != nil {
+		r.formatters[t] = format
+	}
+}
+
+// RegisterType is a type safe version of Registry.Register for type T.
+func RegisterType[T any](r *Registry, parse func(value string) (T, error), format func(v T) (string, error)) {
+	var (
+		parseFunc  ParseFunc
+		formatFunc FormatFunc
+	)
+	if parse != nil {
+		parseFunc = func(value string) (interface{}, error) {
+			return parse(value)
+		}
+	}
+	if format != nil {
+		formatFunc = func(v interface{}) (string, error) {
+			return format(v.(T))
+		}
+	}
+	r.Register(reflect.TypeOf((*T)(nil)).Elem(), parseFunc, formatFunc)
+}
+
+// parse parses value into f, a value of type t, and reports whether a parse
+// function is registered for t.
+func (r *Registry) parse(t reflect.Type, f reflect.Value, value string) (bool, error) {
+	if r == nil {
+		return false, nil
+	}
+	r.mu.RLock()
+	parse, ok := r.parsers[t]
+	r.mu.RUnlock()
+	if !ok {
+		return false, nil
+	}
+
+	v, err := parse(value)
+	if err != nil {
+		return true, err
+	}
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || !rv.Type().AssignableTo(t) {
+		return true, fmt.Errorf("parse function for %s returned a %T", t, v)
+	}
+	f.Set(rv)
+	return true, nil
+}
+
+// format formats v and reports whether a format function is registered for
+// its type.
+func (r *Registry) format(v reflect.Value) (string, bool, error) {
+	if r == nil {
+		return "", false, nil
+	}
+	r.mu.RLock()
+	format, ok := r.formatters[v.Type()]
+	r.mu.RUnlock()
+	if !ok {
+		return "", false, nil
+	}
+
+	s, err := format(v.Interface())
+	return s, true, err
+}
+
+// hasFormatter reports whether a format function is registered for t, or for
+// the type t points to.
+func (r *Registry) hasFormatter(t reflect.Type) bool {
+	if r == nil {
+		return false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if _, ok := r.formatters[t]; ok {
+		return true
+	}
+	if t.Kind() == reflect.Ptr {
+		_, ok := r.formatters[t.Elem()]
+		return ok
+	}
+	return false
+}
+
+// hasParser reports whether a parse function is registered for t.
+func (r *Registry) hasParser(t reflect.Type) bool {
+	if r == nil {
+		return false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.parsers[t]
+	return ok
+}
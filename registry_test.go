@@ -0,0 +1,153 @@
+// Copyright 2018 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package env
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Money stands for a type from another module, which cannot implement
+// Unmarshaler and Marshaler.
+type Money struct {
+	Cents    int64
+	Currency string
+}
+
+// Region stands for a string type from another module.
+type Region string
+
+var errInvalidRegion = errors.New("invalid region")
+
+func newTestRegistry() *Registry {
+	r := NewRegistry()
+	RegisterType(r, func(value string) (Money, error) {
+		var m Money
+		if _, err := fmt.Sscanf(value, "%d %s", &m.Cents, &m.Currency); err != nil {
+			return Money{}, err
+		}
+		return m, nil
+	}, func(m Money) (string, error) {
+		return fmt.Sprintf("%d %s", m.Cents, m.Currency), nil
+	})
+	RegisterType(r, func(value string) (Region, error) {
+		if value != strings.ToLower(value) {
+			return "", errInvalidRegion
+		}
+		return Region(value), nil
+	}, nil)
+	RegisterType(r, func(value string) (time.Time, error) {
+		return time.Parse(time.DateOnly, value)
+	}, func(t time.Time) (string, error) {
+		return t.Format(time.DateOnly), nil
+	})
+	return r
+}
+
+type RegistryStruct struct {
+	Money        Money            `env:"MONEY"`
+	PointerMoney *Money           `env:"POINTER_MONEY"`
+	Prices       []Money          `env:"PRICES"`
+	Regions      []Region         `env:"REGIONS"`
+	Budgets      map[Region]Money `env:"BUDGETS,separator=;,kvseparator=="`
+	Date         time.Time        `env:"DATE"`
+}
+
+func TestUnmarshalRegistry(t *testing.T) {
+	t.Parallel()
+	var (
+		environ = map[string]string{
+			"MONEY":         "100 USD",
+			"POINTER_MONEY": "200 EUR",
+			"PRICES":        "1 USD|2 USD",
+			"REGIONS":       "us-east-1|eu-west-1",
+			"BUDGETS":       "us-east-1=300 USD",
+			"DATE":          "2016-07-15",
+		}
+		registryStruct RegistryStruct
+	)
+
+	if err := NewDecoder(WithRegistry(newTestRegistry())).Unmarshal(environ, &registryStruct); err != nil {
+		t.Errorf("Expected no error but got '%s'", err)
+	}
+
+	testCases := [][]interface{}{
+		{registryStruct.Money, Money{100, "USD"}},
+		{registryStruct.PointerMoney, &Money{200, "EUR"}},
+		{registryStruct.Prices, []Money{{1, "USD"}, {2, "USD"}}},
+		{registryStruct.Regions, []Region{"us-east-1", "eu-west-1"}},
+		{registryStruct.Budgets, map[Region]Money{"us-east-1": {300, "USD"}}},
+		{registryStruct.Date, time.Date(2016, 7, 15, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, testCase := range testCases {
+		if !reflect.DeepEqual(testCase[0], testCase[1]) {
+			t.Errorf("Expected field value to be '%v' but got '%v'", testCase[1], testCase[0])
+		}
+	}
+}
+
+func TestUnmarshalRegistryError(t *testing.T) {
+	t.Parallel()
+	var (
+		environ        = map[string]string{"REGIONS": "us-east-1|EU-WEST-1"}
+		registryStruct RegistryStruct
+	)
+
+	err := NewDecoder(WithRegistry(newTestRegistry())).Unmarshal(environ, &registryStruct)
+	if !errors.Is(err, errInvalidRegion) {
+		t.Errorf("Expected error 'errInvalidRegion' but got '%v'", err)
+	}
+
+	r := NewRegistry()
+	r.Register(reflect.TypeOf(Money{}), func(value string) (interface{}, error) {
+		return value, nil
+	}, nil)
+	environ = map[string]string{"MONEY": "100 USD"}
+	if err := NewDecoder(WithRegistry(r)).Unmarshal(environ, &registryStruct); err == nil {
+		t.Errorf("Expected an error for a parse function returning the wrong type but got none")
+	}
+}
+
+func TestMarshalRegistry(t *testing.T) {
+	t.Parallel()
+	registryStruct := RegistryStruct{
+		Money:        Money{100, "USD"},
+		PointerMoney: &Money{200, "EUR"},
+		Prices:       []Money{{1, "USD"}, {2, "USD"}},
+		Budgets:      map[Region]Money{"us-east-1": {300, "USD"}},
+		Date:         time.Date(2016, 7, 15, 0, 0, 0, 0, time.UTC),
+	}
+
+	es, err := NewEncoder(WithRegistry(newTestRegistry())).Marshal(&registryStruct)
+	if err != nil {
+		t.Errorf("Expected no error but got '%s'", err)
+	}
+
+	expected := map[string]string{
+		"MONEY":         "100 USD",
+		"POINTER_MONEY": "200 EUR",
+		"PRICES":        "1 USD|2 USD",
+		"BUDGETS":       "us-east-1=300 USD",
+		"DATE":          "2016-07-15",
+	}
+	for k, v := range expected {
+		if es[k] != v {
+			t.Errorf("Expected field value to be '%s' but got '%s'", v, es[k])
+		}
+	}
+}
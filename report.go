@@ -0,0 +1,85 @@
+// Copyright 2018 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package env
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	// reportDefault is the Key of the FieldReport of a field set from the
+	// default tag option
+	reportDefault = "default"
+	// reportUnset is the Key of the FieldReport of a field without value
+	reportUnset = "unset"
+)
+
+// Report describes where the value of each field comes from. It prints as a
+// table with String, and can be encoded with encoding/json.
+type Report []FieldReport
+
+// FieldReport describes where the value of a field comes from.
+type FieldReport struct {
+	// Field is the dotted path of the field from the root struct. Map fields
+	// reading every variable with a prefix have one FieldReport per variable.
+	Field string `json:"field"`
+	// Key is the key the value was read from, or "default" if the value is
+	// the default tag option, or "unset" if the field has no value
+	Key string `json:"key"`
+	// Value is the raw value, before any expansion or parsing, or
+	// "[REDACTED]" if Secret is set
+	Value string `json:"value"`
+	// Secret is set for fields tagged with "secret=true"
+	Secret bool `json:"secret"`
+}
+
+// String returns r formatted as a table, with one line per field.
+func (r Report) String() string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "FIELD\tKEY\tVALUE\tSECRET")
+	for _, f := range r {
+		fmt.Fprintf(w, "%s\t%s\t%q\t%t\n", f.Field, f.Key, f.Value, f.Secret)
+	}
+	w.Flush()
+	return b.String()
+}
+
+// WithReport makes the Decoder fill r with the source of the value of every
+// field it visits, in the order the fields are declared. r is reset on every
+// call, so a Decoder created with WithReport must not be used concurrently.
+func WithReport(r *Report) Option {
+	return func(c *config) {
+		c.report = r
+	}
+}
+
+// record adds the source of the value of the field at the given path to the
+// report, if any.
+func (d *decodeState) record(path, key, value string, envTag tag) {
+	if d.config.report == nil {
+		return
+	}
+	if envTag.Secret && value != "" {
+		value = redactedValue
+	}
+	*d.config.report = append(*d.config.report, FieldReport{
+		Field:  path,
+		Key:    key,
+		Value:  value,
+		Secret: envTag.Secret,
+	})
+}
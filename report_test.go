@@ -0,0 +1,80 @@
+// Copyright 2018 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package env
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type ReportStruct struct {
+	Port     int    `env:"PORT,HTTP_PORT,default=8080"`
+	Host     string `env:"HOST,default=localhost"`
+	Password string `env:"PASSWORD,secret=true"`
+	Token    string `env:"TOKEN,secret=true"`
+	Database struct {
+		Name string `env:"NAME"`
+	} `envPrefix:"DB_"`
+	Labels map[string]string `env:"LABEL_*"`
+}
+
+func TestUnmarshalReport(t *testing.T) {
+	t.Parallel()
+	var (
+		environ = map[string]string{
+			"HTTP_PORT":  "9090",
+			"PASSWORD":   "hunter2",
+			"LABEL_team": "core",
+			"LABEL_env":  "prod",
+		}
+		reportStruct ReportStruct
+		report       Report
+	)
+
+	if err := NewDecoder(WithReport(&report)).Unmarshal(environ, &reportStruct); err != nil {
+		t.Errorf("Expected no error but got '%s'", err)
+	}
+
+	expected := Report{
+		{Field: "Port", Key: "HTTP_PORT", Value: "9090"},
+		{Field: "Host", Key: "default", Value: "localhost"},
+		{Field: "Password", Key: "PASSWORD", Value: "[REDACTED]", Secret: true},
+		{Field: "Token", Key: "unset", Secret: true},
+		{Field: "Database.Name", Key: "unset"},
+		{Field: "Labels", Key: "LABEL_env", Value: "prod"},
+		{Field: "Labels", Key: "LABEL_team", Value: "core"},
+	}
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("Expected report to be '%v' but got '%v'", expected, report)
+	}
+
+	table := report.String()
+	lines := strings.Split(table, "\n")
+	if expectedLine := `Port           HTTP_PORT   "9090"        false`; len(lines) < 2 || lines[1] != expectedLine {
+		t.Errorf("Expected table line to be '%s' but got '%s'", expectedLine, table)
+	}
+	if strings.Contains(table, "hunter2") {
+		t.Errorf("Expected table to not contain secret '%s' but got '%s'", "hunter2", table)
+	}
+
+	b, err := json.Marshal(report[:1])
+	if err != nil {
+		t.Errorf("Expected no error but got '%s'", err)
+	}
+	if expectedJSON := `[{"field":"Port","key":"HTTP_PORT","value":"9090","secret":false}]`; string(b) != expectedJSON {
+		t.Errorf("Expected JSON to be '%s' but got '%s'", expectedJSON, b)
+	}
+}
@@ -0,0 +1,304 @@
+// Copyright 2018 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package env
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+	"time"
+)
+
+type Level string
+
+type RoundTripStruct struct {
+	String   string            `env:"STRING"`
+	Bool     bool              `env:"BOOL"`
+	Int      int               `env:"INT"`
+	Int8     int8              `env:"INT8"`
+	Uint16   uint16            `env:"UINT16"`
+	Uint64   uint64            `env:"UINT64"`
+	Float32  float32           `env:"FLOAT32"`
+	Float64  float64           `env:"FLOAT64"`
+	Duration time.Duration     `env:"DURATION"`
+	Pointer  *int              `env:"POINTER"`
+	Level    Level             `env:"LEVEL"`
+	IP       net.IP            `env:"IP"`
+	Strings  []string          `env:"STRINGS"`
+	Commas   []string          `env:"COMMAS,separator=','"`
+	Ints     []int             `env:"INTS"`
+	Floats   []float64         `env:"FLOATS,separator=;"`
+	Bools    []bool            `env:"BOOLS"`
+	Levels   []Level           `env:"LEVELS"`
+	Pointers []*uint           `env:"POINTERS"`
+	Durs     []time.Duration   `env:"DURS"`
+	IPs      []net.IP          `env:"IPS"`
+	Tags     map[string]string `env:"TAGS"`
+	Weights  map[int]float32   `env:"WEIGHTS,separator=;,kvseparator=="`
+	Secret   Secret[string]    `env:"SECRET"`
+	Nested   struct {
+		Ints []int8 `env:"INTS"`
+	} `envPrefix:"NESTED_"`
+}
+
+// roundTripAlphabet holds the characters of generated strings, leaving out the
+// separators, which Generate only adds to Tags.
+const roundTripAlphabet = "abcXYZ019 _-./@#$'\"\\é"
+
+func randomString(r *rand.Rand, nonEmpty bool) string {
+	n := r.Intn(8)
+	if nonEmpty {
+		n++
+	}
+	runes := []rune(roundTripAlphabet)
+	s := make([]rune, n)
+	for i := range s {
+		s[i] = runes[r.Intn(len(runes))]
+	}
+	return string(s)
+}
+
+// Generate implements quick.Generator, with values that can be represented.
+// Slices and maps are never nil, as Unmarshal reads empty values back as empty
+// slices and maps. Slice elements are never nil, empty or holding the
+// separator, which Marshal rejects, as checked by
+// TestMarshalUnrepresentableSlices. The entries of Tags can hold separators,
+// making the struct unrepresentable when they are not read back.
+func (RoundTripStruct) Generate(r *rand.Rand, size int) reflect.Value {
+	var s RoundTripStruct
+	s.String = randomString(r, false)
+	s.Bool = r.Intn(2) == 0
+	s.Int = r.Int() - math.MaxInt/2
+	s.Int8 = int8(r.Intn(256) - 128)
+	s.Uint16 = uint16(r.Intn(math.MaxUint16 + 1))
+	s.Uint64 = r.Uint64()
+	s.Float32 = float32(r.NormFloat64() * 1e6)
+	s.Float64 = r.NormFloat64() * math.Pow(10, float64(r.Intn(40)-20))
+	s.Duration = time.Duration(r.Int63n(int64(100*time.Hour)) - int64(50*time.Hour))
+	if r.Intn(2) == 0 {
+		i := r.Int()
+		s.Pointer = &i
+	}
+	s.Level = Level(randomString(r, false))
+	s.IP = net.IPv4(byte(r.Intn(256)), byte(r.Intn(256)), byte(r.Intn(256)), byte(r.Intn(256)))
+	s.Secret = NewSecret(randomString(r, false))
+
+	s.Strings, s.Commas, s.Levels = []string{}, []string{}, []Level{}
+	s.Ints, s.Floats, s.Bools = []int{}, []float64{}, []bool{}
+	s.Pointers, s.Durs, s.IPs = []*uint{}, []time.Duration{}, []net.IP{}
+	s.Nested.Ints = []int8{}
+	s.Tags, s.Weights = map[string]string{}, map[int]float32{}
+	for range r.Intn(size + 1) {
+		s.Strings = append(s.Strings, randomString(r, true))
+		s.Commas = append(s.Commas, randomString(r, true)+"|")
+		s.Ints = append(s.Ints, r.Intn(2000)-1000)
+		s.Floats = append(s.Floats, r.ExpFloat64())
+		s.Bools = append(s.Bools, r.Intn(2) == 0)
+		s.Levels = append(s.Levels, Level(randomString(r, true)))
+		u := uint(r.Uint32())
+		s.Pointers = append(s.Pointers, &u)
+		s.Durs = append(s.Durs, time.Duration(r.Int63()))
+		s.IPs = append(s.IPs, net.ParseIP("2001:db8::"+string("0123456789abcdef"[r.Intn(16)])))
+		s.Nested.Ints = append(s.Nested.Ints, int8(r.Intn(256)-128))
+		s.Tags[randomString(r, true)] = randomString(r, false) + ":" + randomString(r, false)
+		s.Weights[r.Intn(100)-50] = float32(r.Intn(1000)) / 8
+	}
+	switch r.Intn(8) {
+	case 0:
+		s.Tags[randomString(r, false)+","] = randomString(r, false)
+	case 1:
+		s.Tags[randomString(r, false)+":"] = randomString(r, false)
+	case 2:
+		s.Tags[randomString(r, true)] = randomString(r, false) + ","
+	}
+	return reflect.ValueOf(s)
+}
+
+func TestMarshalUnmarshalRoundTrip(t *testing.T) {
+	t.Parallel()
+	roundTrip := func(in RoundTripStruct) bool {
+		es, err := Marshal(&in)
+		if !representableTags(in.Tags) {
+			if !errors.Is(err, ErrUnrepresentable) {
+				t.Logf("Expected error 'ErrUnrepresentable' but got '%v'", err)
+				return false
+			}
+			return true
+		}
+		if err != nil {
+			t.Logf("Expected no error but got '%s'", err)
+			return false
+		}
+
+		var out RoundTripStruct
+		if err := Unmarshal(es, &out); err != nil {
+			t.Logf("Expected no error but got '%s' for '%v'", err, es)
+			return false
+		}
+
+		// Secrets are compared through their value, and IPs through their
+		// canonical form
+		if in.Secret.Value() != out.Secret.Value() {
+			return false
+		}
+		in.Secret, out.Secret = Secret[string]{}, Secret[string]{}
+		if !in.IP.Equal(out.IP) {
+			return false
+		}
+		in.IP, out.IP = nil, nil
+
+		if !reflect.DeepEqual(in, out) {
+			t.Logf("Expected '%+v' but got '%+v' from '%v'", in, out, es)
+			return false
+		}
+		return true
+	}
+
+	if err := quick.Check(roundTrip, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
+
+// representableTags reports whether the entries of tags can be read back with
+// the default map separators.
+func representableTags(tags map[string]string) bool {
+	for k, v := range tags {
+		if strings.ContainsAny(k, ",:") || strings.Contains(v, ",") {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMarshalSlices(t *testing.T) {
+	t.Parallel()
+	one, two := 1, 2
+	slicesStruct := struct {
+		Strings  []string  `env:"STRINGS,separator=','"`
+		Ints     []int     `env:"INTS"`
+		Pointers []*int    `env:"POINTERS,separator=;"`
+		Levels   []Level   `env:"LEVELS"`
+		Floats   []float32 `env:"FLOATS"`
+		Empty    []int     `env:"EMPTY"`
+	}{
+		Strings:  []string{"a", "b"},
+		Ints:     []int{1, -2},
+		Pointers: []*int{&one, &two},
+		Levels:   []Level{"debug", "info"},
+		Floats:   []float32{0.1, 2.5},
+	}
+
+	es, err := Marshal(&slicesStruct)
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+
+	expected := EnvSet{
+		"STRINGS":  "a,b",
+		"INTS":     "1|-2",
+		"POINTERS": "1;2",
+		"LEVELS":   "debug|info",
+		"FLOATS":   "0.1|2.5",
+		"EMPTY":    "",
+	}
+	if !reflect.DeepEqual(es, expected) {
+		t.Errorf("Expected EnvSet to be '%v' but got '%v'", expected, es)
+	}
+}
+
+func TestMarshalUnrepresentableSlices(t *testing.T) {
+	t.Parallel()
+	one := 1
+	testCases := []interface{}{
+		&struct {
+			Strings []string `env:"STRINGS"`
+		}{[]string{""}},
+		&struct {
+			Levels []Level `env:"LEVELS"`
+		}{[]Level{""}},
+		&struct {
+			Pointers []*int `env:"POINTERS"`
+		}{[]*int{&one, nil}},
+		&struct {
+			Strings []string `env:"STRINGS"`
+		}{[]string{"a|b"}},
+		&struct {
+			Strings []string `env:"STRINGS,separator=','"`
+		}{[]string{"a", "b,c"}},
+	}
+	for _, testCase := range testCases {
+		if es, err := Marshal(testCase); !errors.Is(err, ErrUnrepresentable) {
+			t.Errorf("Expected error 'ErrUnrepresentable' but got '%v' and '%v'", err, es)
+		}
+	}
+
+	// Empty elements are kept when the slice has several elements
+	es, err := Marshal(&struct {
+		Strings []string `env:"STRINGS"`
+	}{[]string{"", "a", ""}})
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+	if es["STRINGS"] != "|a|" {
+		t.Errorf("Expected field value to be '%s' but got '%s'", "|a|", es["STRINGS"])
+	}
+
+	var out struct {
+		Strings []string `env:"STRINGS"`
+		Empty   []string `env:"EMPTY"`
+	}
+	if err := Unmarshal(EnvSet{"STRINGS": "|a|", "EMPTY": ""}, &out); err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+	if !reflect.DeepEqual(out.Strings, []string{"", "a", ""}) {
+		t.Errorf("Expected field value to be '%q' but got '%q'", []string{"", "a", ""}, out.Strings)
+	}
+	if out.Empty == nil || len(out.Empty) != 0 {
+		t.Errorf("Expected field value to be an empty slice but got '%q'", out.Empty)
+	}
+}
+
+func TestMarshalUnrepresentableMaps(t *testing.T) {
+	t.Parallel()
+	testCases := []map[string]string{
+		{"a,b": "c:d", "k": "v,w"},
+		{"a,b": "c"},
+		{"a:b": "c"},
+		{"k": "v,w"},
+	}
+	for _, testCase := range testCases {
+		in := struct {
+			Tags map[string]string `env:"TAGS"`
+		}{testCase}
+		if es, err := Marshal(&in); !errors.Is(err, ErrUnrepresentable) {
+			t.Errorf("Expected error 'ErrUnrepresentable' but got '%v' and '%v'", err, es)
+		}
+	}
+
+	in := struct {
+		Tags map[string]string `env:"TAGS,separator=;,kvseparator=="`
+	}{map[string]string{"a,b": "c:d=e"}}
+	es, err := Marshal(&in)
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+	if es["TAGS"] != "a,b=c:d=e" {
+		t.Errorf("Expected field value to be '%s' but got '%s'", "a,b=c:d=e", es["TAGS"])
+	}
+}
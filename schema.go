@@ -0,0 +1,211 @@
+// Copyright 2018 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package env
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// jsonSchemaDraft is the JSON Schema dialect of the documents returned by
+	// JSONSchema
+	jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+	// schemaIndex stands for the index of slices of structs while walking
+	// the fields, to be replaced by a regular expression matching any index
+	schemaIndex = "\x00"
+)
+
+// Patterns matching the values accepted by Unmarshal for the basic types.
+const (
+	boolPattern     = `^(1|t|T|TRUE|true|True|0|f|F|FALSE|false|False)$`
+	intPattern      = `^[+-]?[0-9]+$`
+	uintPattern     = `^[0-9]+$`
+	floatPattern    = `^[+-]?(([0-9]+(\.[0-9]*)?|\.[0-9]+)([eE][+-]?[0-9]+)?|[iI][nN][fF]([iI][nN][iI][tT][yY])?|[nN][aA][nN])$`
+	durationPattern = `^([+-]?(([0-9]+(\.[0-9]*)?|\.[0-9]+)(ns|us|µs|μs|ms|s|m|h))+|[+-]?0)$`
+)
+
+// jsonSchema is the subset of JSON Schema used to describe an EnvSet.
+type jsonSchema struct {
+	Schema            string                 `json:"$schema,omitempty"`
+	Title             string                 `json:"title,omitempty"`
+	Type              string                 `json:"type,omitempty"`
+	Description       string                 `json:"description,omitempty"`
+	Default           string                 `json:"default,omitempty"`
+	Enum              []string               `json:"enum,omitempty"`
+	Pattern           string                 `json:"pattern,omitempty"`
+	MinLength         *int                   `json:"minLength,omitempty"`
+	MaxLength         *int                   `json:"maxLength,omitempty"`
+	WriteOnly         bool                   `json:"writeOnly,omitempty"`
+	Properties        map[string]*jsonSchema `json:"properties,omitempty"`
+	PatternProperties map[string]*jsonSchema `json:"patternProperties,omitempty"`
+	Required          []string               `json:"required,omitempty"`
+	AllOf             []*jsonSchema          `json:"allOf,omitempty"`
+	AnyOf             []*jsonSchema          `json:"anyOf,omitempty"`
+}
+
+// JSONSchema returns a JSON Schema document describing the EnvSets that
+// Unmarshal accepts for v, a struct or a pointer to a struct. See
+// Decoder.JSONSchema.
+func JSONSchema(v interface{}) ([]byte, error) {
+	return NewDecoder().JSONSchema(v)
+}
+
+// JSONSchema returns a JSON Schema document describing the EnvSets that d
+// accepts for v, a struct or a pointer to a struct. The document describes an
+// object with a string property per key, other properties being allowed.
+//
+// Each property has the "desc" struct field tag of its field as description,
+// its default, and a pattern matching the values of its Go type, for basic
+// types and time.Duration. The "oneof" tag option gives an enum, the
+// "pattern" tag option a pattern replacing the one of the type, and the "min",
+// "max" and "notempty" tag options length bounds for string fields. Secrets
+// are marked as write only. Required fields without default are listed as
+// required, or as any of their keys being required if they have several.
+//
+// Map fields reading every variable with a prefix, and the fields of slices of
+// structs, are described with pattern properties.
+func (d *Decoder) JSONSchema(v interface{}) ([]byte, error) {
+	schema := &jsonSchema{
+		Schema:     jsonSchemaDraft,
+		Type:       "object",
+		Properties: make(map[string]*jsonSchema),
+	}
+	if t := reflect.TypeOf(v); t != nil {
+		schema.Title = strings.TrimPrefix(t.String(), "*")
+	}
+
+	err := d.config.walkFields(v, schemaIndex, func(f fieldInfo) error {
+		property := d.config.fieldSchema(f)
+
+		var keys []string
+		for _, key := range f.tag.Keys {
+			if prefix, ok := strings.CutSuffix(key, "*"); ok {
+				addPatternProperty(schema, keyPattern(prefix)+".+$", property)
+				continue
+			}
+			if f.indexed {
+				addPatternProperty(schema, keyPattern(key)+"$", property)
+				continue
+			}
+			schema.Properties[key] = property
+			keys = append(keys, key)
+		}
+
+		if !f.tag.Required || f.tag.Default != "" || len(keys) == 0 {
+			return nil
+		}
+		if len(keys) == 1 {
+			schema.Required = append(schema.Required, keys[0])
+			return nil
+		}
+		anyOf := &jsonSchema{}
+		for _, key := range keys {
+			anyOf.AnyOf = append(anyOf.AnyOf, &jsonSchema{Required: []string{key}})
+		}
+		schema.AllOf = append(schema.AllOf, anyOf)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// keyPattern returns a regular expression matching the start of key, in which
+// the indexes of slices of structs match any index.
+func keyPattern(key string) string {
+	return "^" + strings.ReplaceAll(regexp.QuoteMeta(key), schemaIndex, "[0-9]+")
+}
+
+// addPatternProperty adds property to the pattern properties of schema.
+func addPatternProperty(schema *jsonSchema, pattern string, property *jsonSchema) {
+	if schema.PatternProperties == nil {
+		schema.PatternProperties = make(map[string]*jsonSchema)
+	}
+	schema.PatternProperties[pattern] = property
+}
+
+// fieldSchema returns the schema of the values of f.
+func (c *config) fieldSchema(f fieldInfo) *jsonSchema {
+	property := &jsonSchema{
+		Type:        "string",
+		Description: f.desc,
+		Default:     f.tag.Default,
+		WriteOnly:   f.tag.Secret,
+	}
+
+	t := f.typ
+	for {
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+			continue
+		}
+		if s, ok := reflect.New(t).Elem().Interface().(secret); ok {
+			t = s.secretValue().Type()
+			continue
+		}
+		break
+	}
+
+	switch {
+	case len(f.tag.OneOf) > 0:
+		property.Enum = f.tag.OneOf
+	case f.tag.Pattern != "":
+		property.Pattern = "^(?:" + f.tag.Pattern + ")$"
+	default:
+		property.Pattern = c.typePattern(t)
+	}
+
+	if t.Kind() == reflect.String && !implementsUnmarshaler(reflect.PointerTo(t)) {
+		if n, err := strconv.Atoi(f.tag.Min); err == nil {
+			property.MinLength = &n
+		}
+		if n, err := strconv.Atoi(f.tag.Max); err == nil {
+			property.MaxLength = &n
+		}
+	}
+	if f.tag.NotEmpty && (property.MinLength == nil || *property.MinLength < 1) {
+		n := 1
+		property.MinLength = &n
+	}
+	return property
+}
+
+// typePattern returns a regular expression matching the values Unmarshal
+// accepts for t, or "" if t accepts any value or is not a basic type.
+func (c *config) typePattern(t reflect.Type) string {
+	if c.registry.hasParser(t) || implementsUnmarshaler(reflect.PointerTo(t)) {
+		return ""
+	}
+	if t == durationType {
+		return durationPattern
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return boolPattern
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return intPattern
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return uintPattern
+	case reflect.Float32, reflect.Float64:
+		return floatPattern
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2018 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package env
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+type SchemaStruct struct {
+	Port     int               `env:"PORT,HTTP_PORT,required=true" desc:"port to listen on"`
+	Host     string            `env:"HOST,required=true,min=1,max=253"`
+	LogLevel string            `env:"LOG_LEVEL,default=info,oneof=debug|info|warn"`
+	Version  string            `env:"VERSION,pattern=v[0-9]+"`
+	Debug    bool              `env:"DEBUG"`
+	Ratio    *float64          `env:"RATIO"`
+	Timeout  time.Duration     `env:"TIMEOUT,default=5s"`
+	Token    Secret[string]    `env:"TOKEN,required=true,notempty=true"`
+	Labels   map[string]string `env:"LABEL_*"`
+	Backends []struct {
+		Weight uint `env:"WEIGHT,required=true"`
+	} `envPrefix:"BACKEND_"`
+}
+
+func TestJSONSchema(t *testing.T) {
+	t.Parallel()
+	b, err := JSONSchema(&SchemaStruct{})
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+
+	var schema jsonSchema
+	if err := json.Unmarshal(b, &schema); err != nil {
+		t.Fatalf("Expected valid JSON but got '%s'", err)
+	}
+
+	one := 1
+	testCases := [][]interface{}{
+		{schema.Schema, jsonSchemaDraft},
+		{schema.Title, "env.SchemaStruct"},
+		{schema.Type, "object"},
+		{schema.Required, []string{"HOST", "TOKEN"}},
+		{schema.AllOf, []*jsonSchema{{AnyOf: []*jsonSchema{{Required: []string{"PORT"}}, {Required: []string{"HTTP_PORT"}}}}}},
+		{schema.Properties["PORT"], &jsonSchema{Type: "string", Description: "port to listen on", Pattern: intPattern}},
+		{schema.Properties["HTTP_PORT"], schema.Properties["PORT"]},
+		{schema.Properties["LOG_LEVEL"], &jsonSchema{Type: "string", Default: "info", Enum: []string{"debug", "info", "warn"}}},
+		{schema.Properties["VERSION"], &jsonSchema{Type: "string", Pattern: "^(?:v[0-9]+)$"}},
+		{schema.Properties["TIMEOUT"].Pattern, durationPattern},
+		{schema.Properties["RATIO"].Pattern, floatPattern},
+		{*schema.Properties["HOST"].MaxLength, 253},
+		{schema.Properties["TOKEN"], &jsonSchema{Type: "string", MinLength: &one, WriteOnly: true}},
+		{schema.PatternProperties["^LABEL_.+$"], &jsonSchema{Type: "string"}},
+		{schema.PatternProperties["^BACKEND_[0-9]+_WEIGHT$"], &jsonSchema{Type: "string", Pattern: uintPattern}},
+		{len(schema.Properties), 9},
+	}
+	for _, testCase := range testCases {
+		if !reflect.DeepEqual(testCase[0], testCase[1]) {
+			t.Errorf("Expected schema value to be '%v' but got '%v'", testCase[1], testCase[0])
+		}
+	}
+}
+
+func TestJSONSchemaPatterns(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		pattern string
+		valid   []string
+		invalid []string
+	}{
+		{boolPattern, []string{"1", "true", "F", "FALSE"}, []string{"yes", "tRUE", ""}},
+		{intPattern, []string{"0", "-12", "+7"}, []string{"1.5", "0x10", ""}},
+		{uintPattern, []string{"0", "42"}, []string{"-1", "+1", ""}},
+		{floatPattern, []string{"1", "-1.5", ".5", "1e10", "Inf", "NaN"}, []string{"1.2.3", "e5", ""}},
+		{durationPattern, []string{"0", "5s", "1h30m", "-1.5ms", "10µs"}, []string{"5", "1d", ""}},
+	}
+	for _, testCase := range testCases {
+		re := regexp.MustCompile(testCase.pattern)
+		for _, value := range testCase.valid {
+			if !re.MatchString(value) {
+				t.Errorf("Expected '%s' to match '%s'", value, testCase.pattern)
+			}
+		}
+		for _, value := range testCase.invalid {
+			if re.MatchString(value) {
+				t.Errorf("Expected '%s' to not match '%s'", value, testCase.pattern)
+			}
+		}
+	}
+}
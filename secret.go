@@ -0,0 +1,111 @@
+// Copyright 2018 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package env
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+)
+
+// Secret holds a value that must not leak in logs, panics or dumps. Its
+// String, GoString and Format methods, and its slog.LogValuer
+// implementation, print "[REDACTED]" instead of the value, which is only
+// accessible through Value.
+//
+// Unmarshal sets the value of a Secret[T] field like it would set a T field,
+// and treats the field as if it was tagged with "secret=true". Marshal writes
+// the value, unless the Encoder is created with WithRedaction.
+type Secret[T any] struct {
+	value T
+}
+
+// NewSecret returns a Secret holding value.
+func NewSecret[T any](value T) Secret[T] {
+	return Secret[T]{value: value}
+}
+
+// Value returns the value held by s.
+func (s Secret[T]) Value() T {
+	return s.value
+}
+
+// String returns "[REDACTED]".
+func (s Secret[T]) String() string {
+	return redactedValue
+}
+
+// GoString returns "[REDACTED]", for the %#v verb.
+func (s Secret[T]) GoString() string {
+	return redactedValue
+}
+
+// Format writes "[REDACTED]" for every verb, so that none of them, such as
+// %d or %x, prints the value.
+func (s Secret[T]) Format(f fmt.State, verb rune) {
+	io.WriteString(f, redactedValue)
+}
+
+// LogValue returns "[REDACTED]" as a slog.Value.
+func (s Secret[T]) LogValue() slog.Value {
+	return slog.StringValue(redactedValue)
+}
+
+// secretValue returns the value held by s.
+func (s Secret[T]) secretValue() reflect.Value {
+	return reflect.ValueOf(&s.value).Elem()
+}
+
+// secretField returns the settable value held by s.
+func (s *Secret[T]) secretField() reflect.Value {
+	return reflect.ValueOf(&s.value).Elem()
+}
+
+// secret is implemented by Secret[T].
+type secret interface {
+	secretValue() reflect.Value
+}
+
+// settableSecret is implemented by *Secret[T].
+type settableSecret interface {
+	secretField() reflect.Value
+}
+
+var secretType = reflect.TypeOf((*secret)(nil)).Elem()
+
+// isSecretType reports whether t is a Secret[T], or a pointer, slice or map
+// of them.
+func isSecretType(t reflect.Type) bool {
+	for {
+		if t.Implements(secretType) {
+			return true
+		}
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Map:
+			t = t.Elem()
+		default:
+			return false
+		}
+	}
+}
+
+// WithRedaction makes the Encoder write "[REDACTED]" instead of the non empty
+// values of Secret[T] fields and fields tagged with "secret=true", so that the
+// EnvSet can be logged for diagnostics.
+func WithRedaction() Option {
+	return func(c *config) {
+		c.redact = true
+	}
+}
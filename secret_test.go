@@ -0,0 +1,253 @@
+// Copyright 2018 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package env
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type SecretStruct struct {
+	Password Secret[string]   `env:"PASSWORD"`
+	PIN      *Secret[int]     `env:"PIN"`
+	Keys     []Secret[string] `env:"KEYS"`
+	Token    string           `env:"TOKEN,secret=true"`
+	Codes    []int            `env:"CODES,secret=true"`
+	User     string           `env:"USER"`
+}
+
+func TestUnmarshalSecret(t *testing.T) {
+	t.Parallel()
+	var (
+		environ = map[string]string{
+			"PASSWORD": "hunter2",
+			"PIN":      "1234",
+			"KEYS":     "a|b",
+			"TOKEN":    "token",
+			"USER":     "user",
+		}
+		secretStruct SecretStruct
+	)
+
+	if err := Unmarshal(environ, &secretStruct); err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+
+	testCases := [][]interface{}{
+		{secretStruct.Password.Value(), "hunter2"},
+		{secretStruct.PIN.Value(), 1234},
+		{len(secretStruct.Keys), 2},
+		{secretStruct.Keys[1].Value(), "b"},
+	}
+	for _, testCase := range testCases {
+		if testCase[0] != testCase[1] {
+			t.Errorf("Expected field value to be '%v' but got '%v'", testCase[1], testCase[0])
+		}
+	}
+}
+
+func TestSecretFormatting(t *testing.T) {
+	t.Parallel()
+	s := NewSecret("hunter2")
+
+	var logs bytes.Buffer
+	slog.New(slog.NewTextHandler(&logs, nil)).Info("config", "password", s)
+
+	outputs := []string{
+		s.String(),
+		fmt.Sprint(s),
+		fmt.Sprintf("%v %+v %#v %s %q %x", s, s, s, s, s, s),
+		fmt.Sprintf("%v", &s),
+		fmt.Sprintf("%+v", SecretStruct{Password: s}),
+		logs.String(),
+	}
+	for _, output := range outputs {
+		if strings.Contains(output, "hunter2") || !strings.Contains(output, "[REDACTED]") {
+			t.Errorf("Expected output to be redacted but got '%s'", output)
+		}
+	}
+}
+
+func TestUnmarshalSecretErrors(t *testing.T) {
+	t.Parallel()
+	testCases := []map[string]string{
+		{"PIN": "12x4"},
+		{"TOKEN": "12x4", "CODES": "1|12x4"},
+	}
+	for _, environ := range testCases {
+		err := NewDecoder(WithAllErrors()).Unmarshal(environ, &SecretStruct{})
+
+		var numErr *strconv.NumError
+		if !errors.As(err, &numErr) {
+			t.Errorf("Expected error '*strconv.NumError' but got '%v'", err)
+		}
+		if err != nil && strings.Contains(err.Error(), "12x4") {
+			t.Errorf("Expected error to be redacted but got '%s'", err)
+		}
+	}
+}
+
+func TestUnmarshalSecretErrorMessages(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		environ  map[string]string
+		expected string
+	}{
+		{map[string]string{"TOKEN": "x", "CODES": "hunter\"2\n"}, `env: field "Codes" ([]int) for CODES: invalid value for []int`},
+		{map[string]string{"TOKEN": "x", "CODES": "1|a"}, `env: field "Codes" ([]int) for CODES: invalid value for []int`},
+		{map[string]string{"PIN": "'1'\n"}, `env: field "PIN" (*env.Secret[int]) for PIN: invalid value for *env.Secret[int]`},
+	}
+	for _, testCase := range testCases {
+		err := Unmarshal(testCase.environ, &SecretStruct{})
+
+		var numErr *strconv.NumError
+		if !errors.As(err, &numErr) {
+			t.Errorf("Expected error '*strconv.NumError' but got '%v'", err)
+		}
+		if err != nil && err.Error() != testCase.expected {
+			t.Errorf("Expected error to be '%s' but got '%s'", testCase.expected, err)
+		}
+	}
+}
+
+type ValidatedSecretStruct struct {
+	PIN     int           `env:"PIN,secret=true,min=1000"`
+	Timeout time.Duration `env:"TIMEOUT,secret=true,max=10s"`
+	Code    Secret[int]   `env:"CODE,pattern=[0-9]{4}"`
+	Keys    []string      `env:"KEYS,secret=true,oneof=alpha|beta"`
+}
+
+func TestUnmarshalSecretValidation(t *testing.T) {
+	t.Parallel()
+	var validatedSecretStruct ValidatedSecretStruct
+	if err := Unmarshal(map[string]string{"PIN": "1234", "CODE": "4242"}, &validatedSecretStruct); err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+
+	var boundStruct struct {
+		Token Secret[int]    `env:"TOK,min=5"`
+		Name  Secret[string] `env:"NAME,max=4"`
+	}
+	if err := Unmarshal(map[string]string{"TOK": "7", "NAME": "abcd"}, &boundStruct); err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+	if boundStruct.Token.Value() != 7 || boundStruct.Name.Value() != "abcd" {
+		t.Errorf("Expected field values to be '%d' and '%s' but got '%d' and '%s'", 7, "abcd", boundStruct.Token.Value(), boundStruct.Name.Value())
+	}
+
+	err := Unmarshal(map[string]string{"TOK": "3"}, &boundStruct)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Rule != "min=5" {
+		t.Errorf("Expected error 'ValidationError' for rule '%s' but got '%v'", "min=5", err)
+	}
+}
+
+func TestUnmarshalSecretValidationErrors(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		environ map[string]string
+		leaked  string
+	}{
+		{map[string]string{"PIN": "0123"}, "123"},
+		{map[string]string{"TIMEOUT": "1m"}, "1m0s"},
+		{map[string]string{"CODE": "07"}, "7"},
+		{map[string]string{"KEYS": "alpha|gamma"}, "gamma"},
+	}
+	for _, testCase := range testCases {
+		err := Unmarshal(testCase.environ, &ValidatedSecretStruct{})
+
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Errorf("Expected error 'ValidationError' for '%v' but got '%v'", testCase.environ, err)
+			continue
+		}
+		if validationErr.Value != redactedValue {
+			t.Errorf("Expected value to be '%s' but got '%s'", redactedValue, validationErr.Value)
+		}
+		if strings.Contains(err.Error(), testCase.leaked) {
+			t.Errorf("Expected error to be redacted but got '%s'", err)
+		}
+	}
+}
+
+type BareSecretStruct struct {
+	Token string `env:"TOKEN,secret"`
+	PIN   int    `env:"PIN,secret,max=9999"`
+}
+
+func TestUnmarshalBareSecret(t *testing.T) {
+	t.Parallel()
+	decoder := NewDecoder(WithStrictTags(), WithKeepEnvSet())
+	environ := map[string]string{"TOKEN": "token", "PIN": "12345", "secret": "other"}
+
+	var bareSecretStruct BareSecretStruct
+	err := decoder.Unmarshal(environ, &bareSecretStruct)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected error 'ValidationError' but got '%v'", err)
+	}
+	if strings.Contains(err.Error(), "12345") {
+		t.Errorf("Expected error to be redacted but got '%s'", err)
+	}
+	if bareSecretStruct.Token != "token" {
+		t.Errorf("Expected field value to be '%s' but got '%s'", "token", bareSecretStruct.Token)
+	}
+
+	es, err := NewEncoder(WithStrictTags(), WithRedaction()).Marshal(&bareSecretStruct)
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+	if !reflect.DeepEqual(es, EnvSet{"TOKEN": redactedValue, "PIN": redactedValue}) {
+		t.Errorf("Expected only the keys to be marshaled and redacted but got '%v'", es)
+	}
+}
+
+func TestMarshalSecret(t *testing.T) {
+	t.Parallel()
+	pin := NewSecret(1234)
+	secretStruct := SecretStruct{
+		Password: NewSecret("hunter2"),
+		PIN:      &pin,
+		Keys:     []Secret[string]{NewSecret("a"), NewSecret("b")},
+		Token:    "token",
+		User:     "user",
+	}
+
+	es, err := Marshal(&secretStruct)
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+	for k, v := range map[string]string{"PASSWORD": "hunter2", "PIN": "1234", "KEYS": "a|b", "TOKEN": "token", "USER": "user"} {
+		if es[k] != v {
+			t.Errorf("Expected field value to be '%s' but got '%s'", v, es[k])
+		}
+	}
+
+	es, err = NewEncoder(WithRedaction()).Marshal(&secretStruct)
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+	for k, v := range map[string]string{"PASSWORD": "[REDACTED]", "PIN": "[REDACTED]", "KEYS": "[REDACTED]", "TOKEN": "[REDACTED]", "CODES": "", "USER": "user"} {
+		if es[k] != v {
+			t.Errorf("Expected field value to be '%s' but got '%s'", v, es[k])
+		}
+	}
+}
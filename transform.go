@@ -16,6 +16,9 @@ package env
 import (
 	"errors"
 	"fmt"
+	"iter"
+	"maps"
+	"slices"
 	"strings"
 )
 
@@ -61,7 +64,8 @@ func EnvironToEnvSet(environ []string) (EnvSet, error) {
 }
 
 // EnvSetToEnviron transforms a EnvSet into a slice of strings with the format
-// "key=value".
+// "key=value". The order of the items is unspecified, see
+// EnvSetToSortedEnviron for a stable one.
 func EnvSetToEnviron(m EnvSet) []string {
 	environ := make([]string, 0, len(m))
 	for k, v := range m {
@@ -69,3 +73,26 @@ func EnvSetToEnviron(m EnvSet) []string {
 	}
 	return environ
 }
+
+// EnvSetToSortedEnviron transforms a EnvSet into a slice of strings with the
+// format "key=value", sorted by key in byte-wise lexical order, such as
+// "A=1", "B=2", "a=3".
+func EnvSetToSortedEnviron(m EnvSet) []string {
+	environ := make([]string, 0, len(m))
+	for k, v := range m.Sorted() {
+		environ = append(environ, fmt.Sprintf("%s=%s", k, v))
+	}
+	return environ
+}
+
+// Sorted returns an iterator over the keys and values of e, sorted by key in
+// byte-wise lexical order like EnvSetToSortedEnviron.
+func (e EnvSet) Sorted() iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) {
+		for _, k := range slices.Sorted(maps.Keys(e)) {
+			if !yield(k, e[k]) {
+				return
+			}
+		}
+	}
+}
@@ -16,6 +16,7 @@ package env
 import (
 	"errors"
 	"fmt"
+	"reflect"
 	"testing"
 )
 
@@ -115,3 +116,47 @@ func TestEnvSetToEnviron(t *testing.T) {
 		}
 	}
 }
+
+func TestEnvSetToSortedEnviron(t *testing.T) {
+	t.Parallel()
+	m := EnvSet{
+		"WORKSPACE": "/mnt/builds/slave/workspace/test",
+		"HOME":      "/home/test",
+		"home":      "lowercase",
+		"A_B":       "1",
+		"AB":        "2",
+	}
+
+	expected := []string{"AB=2", "A_B=1", "HOME=/home/test", "WORKSPACE=/mnt/builds/slave/workspace/test", "home=lowercase"}
+	for range 10 {
+		if environ := EnvSetToSortedEnviron(m); !reflect.DeepEqual(environ, expected) {
+			t.Fatalf("Expected environ to be '%v' but got '%v'", expected, environ)
+		}
+	}
+}
+
+func TestEnvSetSorted(t *testing.T) {
+	t.Parallel()
+	m := EnvSet{"C": "3", "A": "1", "B": "2"}
+
+	var keys, values []string
+	for k, v := range m.Sorted() {
+		keys = append(keys, k)
+		values = append(values, v)
+	}
+	if !reflect.DeepEqual(keys, []string{"A", "B", "C"}) || !reflect.DeepEqual(values, []string{"1", "2", "3"}) {
+		t.Errorf("Expected sorted keys and values but got '%v' and '%v'", keys, values)
+	}
+
+	// Stopping early must not yield any other entry
+	keys = nil
+	for k := range m.Sorted() {
+		keys = append(keys, k)
+		if k == "B" {
+			break
+		}
+	}
+	if !reflect.DeepEqual(keys, []string{"A", "B"}) {
+		t.Errorf("Expected keys to be '%v' but got '%v'", []string{"A", "B"}, keys)
+	}
+}
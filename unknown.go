@@ -0,0 +1,149 @@
+// Copyright 2018 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package env
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// maxSuggestionDistance is the largest edit distance between an unknown key
+// and a known key for the latter to be suggested.
+const maxSuggestionDistance = 3
+
+// UnknownKeyError is returned for each variable whose key starts with one of
+// the prefixes given to WithOwnedPrefixes but matches no field.
+type UnknownKeyError struct {
+	// Key is the key of the variable
+	Key string
+	// Suggestion is the known key closest to Key, if any is close enough
+	Suggestion string
+}
+
+func (e *UnknownKeyError) Error() string {
+	if e.Suggestion == "" {
+		return fmt.Sprintf("env: unknown variable %q", e.Key)
+	}
+	return fmt.Sprintf("env: unknown variable %q, did you mean %q?", e.Key, e.Suggestion)
+}
+
+// WithOwnedPrefixes makes the Decoder return an *UnknownKeyError for every
+// variable whose key starts with one of prefixes, but that no field reads.
+// This catches typos such as MYAPP_TIMOUT, which would otherwise be ignored
+// silently in favor of a default.
+func WithOwnedPrefixes(prefixes ...string) Option {
+	return func(c *config) {
+		c.ownedPrefixes = append(c.ownedPrefixes, prefixes...)
+	}
+}
+
+// knownKeys are the keys read by the fields of a struct, recorded by the
+// Decoder when WithOwnedPrefixes is used.
+type knownKeys struct {
+	keys map[string]bool
+	// prefixes are the prefixes of map fields reading every variable starting
+	// with them
+	prefixes []string
+}
+
+// add records the keys read by a field with the given tag.
+func (k *knownKeys) add(c *config, envTag tag) {
+	if k.keys == nil {
+		k.keys = make(map[string]bool)
+	}
+	k.prefixes = append(k.prefixes, envTag.prefixes()...)
+	for _, key := range envTag.Keys {
+		if strings.HasSuffix(key, "*") {
+			continue
+		}
+		k.keys[key] = true
+		if c.fileFallback {
+			k.keys[key+fileSuffix] = true
+		}
+	}
+}
+
+// contains reports whether key is read by a field.
+func (k *knownKeys) contains(key string) bool {
+	if k.keys[key] {
+		return true
+	}
+	for _, prefix := range k.prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// unknown returns an *UnknownKeyError for each key of es starting with one of
+// the owned prefixes that is not known, sorted by key.
+func (k *knownKeys) unknown(c *config, es EnvSet) []error {
+	var unknown []string
+	for key := range es {
+		owned := slices.ContainsFunc(c.ownedPrefixes, func(prefix string) bool {
+			return strings.HasPrefix(key, prefix)
+		})
+		if owned && !k.contains(key) {
+			unknown = append(unknown, key)
+		}
+	}
+	slices.Sort(unknown)
+
+	errs := make([]error, len(unknown))
+	for i, key := range unknown {
+		errs[i] = &UnknownKeyError{Key: key, Suggestion: k.suggest(key)}
+	}
+	return errs
+}
+
+// suggest returns the known key closest to key, or "" if none is within
+// maxSuggestionDistance edits. Ties are broken alphabetically.
+func (k *knownKeys) suggest(key string) string {
+	var (
+		suggestion string
+		best       = maxSuggestionDistance + 1
+	)
+	for known := range k.keys {
+		distance := levenshtein(key, known)
+		if distance < best || (distance == best && known < suggestion) {
+			suggestion, best = known, distance
+		}
+	}
+	return suggestion
+}
+
+// levenshtein returns the number of single rune insertions, deletions and
+// substitutions needed to turn a into b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := range ra {
+		curr[0] = i + 1
+		for j := range rb {
+			cost := 1
+			if ra[i] == rb[j] {
+				cost = 0
+			}
+			curr[j+1] = min(prev[j+1]+1, curr[j]+1, prev[j]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
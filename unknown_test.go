@@ -0,0 +1,118 @@
+// Copyright 2018 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package env
+
+import (
+	"errors"
+	"testing"
+)
+
+type OwnedStruct struct {
+	Timeout  int               `env:"MYAPP_TIMEOUT,default=30"`
+	Name     string            `env:"MYAPP_NAME"`
+	Database struct {
+		Host string `env:"HOST"`
+	} `envPrefix:"MYAPP_DB_"`
+	Labels    map[string]string `env:"MYAPP_LABEL_*"`
+	Upstreams []struct {
+		URL string `env:"URL"`
+	} `envPrefix:"MYAPP_UPSTREAM_"`
+}
+
+func TestUnmarshalOwnedPrefixes(t *testing.T) {
+	t.Parallel()
+	var (
+		environ = map[string]string{
+			"MYAPP_NAME":           "name",
+			"MYAPP_DB_HOST":        "localhost",
+			"MYAPP_LABEL_team":     "core",
+			"MYAPP_UPSTREAM_0_URL": "http://a",
+			"MYAPP_NAME_FILE":      "/run/secrets/name",
+			"HOME":                 "/home/test",
+		}
+		ownedStruct OwnedStruct
+	)
+
+	if err := NewDecoder(WithOwnedPrefixes("MYAPP_"), WithFileFallback()).Unmarshal(environ, &ownedStruct); err != nil {
+		t.Errorf("Expected no error but got '%s'", err)
+	}
+}
+
+func TestUnmarshalUnknownKeys(t *testing.T) {
+	t.Parallel()
+	var (
+		environ = map[string]string{
+			"MYAPP_TIMOUT":         "60",
+			"MYAPP_DB_HOTS":        "localhost",
+			"MYAPP_UPSTREAM_0_URL": "http://a",
+			"MYAPP_UPSTREAM_2_URL": "http://c",
+			"MYAPP_SOMETHING_ELSE": "value",
+			"OTHER_TIMOUT":         "60",
+		}
+		ownedStruct OwnedStruct
+	)
+
+	err := NewDecoder(WithOwnedPrefixes("MYAPP_"), WithAllErrors()).Unmarshal(environ, &ownedStruct)
+
+	var errs Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected error 'Errors' but got '%v'", err)
+	}
+
+	testCases := [][]string{
+		{"MYAPP_DB_HOTS", "MYAPP_DB_HOST"},
+		{"MYAPP_SOMETHING_ELSE", ""},
+		{"MYAPP_TIMOUT", "MYAPP_TIMEOUT"},
+		{"MYAPP_UPSTREAM_2_URL", "MYAPP_UPSTREAM_0_URL"},
+	}
+	if len(errs) != len(testCases) {
+		t.Fatalf("Expected %d errors but got %d: '%s'", len(testCases), len(errs), err)
+	}
+	for i, testCase := range testCases {
+		var unknownErr *UnknownKeyError
+		if !errors.As(errs[i], &unknownErr) {
+			t.Errorf("Expected error 'UnknownKeyError' but got '%s'", errs[i])
+			continue
+		}
+		if unknownErr.Key != testCase[0] || unknownErr.Suggestion != testCase[1] {
+			t.Errorf("Expected key '%s' with suggestion '%s' but got '%s' with '%s'",
+				testCase[0], testCase[1], unknownErr.Key, unknownErr.Suggestion)
+		}
+	}
+
+	if ownedStruct.Timeout != 30 {
+		t.Errorf("Expected field value to be '%d' but got '%d'", 30, ownedStruct.Timeout)
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		a, b     string
+		distance int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"TIMOUT", "TIMEOUT", 1},
+		{"HOTS", "HOST", 2},
+		{"kitten", "sitting", 3},
+		{"héllo", "hello", 1},
+	}
+	for _, testCase := range testCases {
+		if distance := levenshtein(testCase.a, testCase.b); distance != testCase.distance {
+			t.Errorf("Expected distance between '%s' and '%s' to be %d but got %d",
+				testCase.a, testCase.b, testCase.distance, distance)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2018 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package env
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// PrintUsage writes to w a description of the environment variables read by
+// Unmarshal for v, a struct or a pointer to a struct. See Decoder.PrintUsage.
+func PrintUsage(w io.Writer, v interface{}) error {
+	return NewDecoder().PrintUsage(w, v)
+}
+
+// PrintUsage writes to w a description of the environment variables read by
+// d for v, a struct or a pointer to a struct, formatted like
+// flag.PrintDefaults. Each field is described by its keys, its Go type, the
+// text of its "desc" struct field tag, its default and whether it is required,
+// such as in:
+//
+//	  PORT, HTTP_PORT int
+//	    	port to listen on (default 8080)
+//
+// Fields of nested structs are grouped under their path, followed by the
+// "desc" tag of the struct field, if any. PrintUsage can be called from
+// flag.Usage, after flag.PrintDefaults.
+func (d *Decoder) PrintUsage(w io.Writer, v interface{}) error {
+	groups, err := d.config.groupFields(v)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	for _, group := range groups {
+		if group.path != "" {
+			b.WriteString("\n" + groupTitle(group) + "\n")
+		}
+		for _, f := range group.fields {
+			writeUsage(&b, f)
+		}
+	}
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// groupTitle returns the path of group, followed by its description, if any.
+func groupTitle(group *structFields) string {
+	if group.desc == "" {
+		return group.path + ":"
+	}
+	return group.path + ": " + group.desc
+}
+
+// writeUsage writes the description of f to b, the way flag.PrintDefaults
+// describes a flag.
+func writeUsage(b *strings.Builder, f fieldInfo) {
+	fmt.Fprintf(b, "  %s %s\n", strings.Join(f.tag.Keys, ", "), f.typ)
+
+	var details []string
+	if f.desc != "" {
+		details = append(details, strings.ReplaceAll(f.desc, "\n", "\n    \t"))
+	}
+	if f.tag.Default != "" {
+		if f.typ.Kind() == reflect.String {
+			details = append(details, fmt.Sprintf("(default %q)", f.tag.Default))
+		} else {
+			details = append(details, fmt.Sprintf("(default %v)", f.tag.Default))
+		}
+	}
+	if f.tag.Required {
+		details = append(details, "(required)")
+	}
+	if len(details) > 0 {
+		b.WriteString("    \t" + strings.Join(details, " ") + "\n")
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2018 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package env
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type UsageStruct struct {
+	Port     int           `env:"PORT,HTTP_PORT,default=8080" desc:"port to listen on"`
+	Home     string        `env:"HOME,required=true" desc:"home directory\nof the service"`
+	Database struct {
+		Host    string        `env:"HOST,default=localhost" desc:"database host"`
+		Timeout time.Duration `env:"TIMEOUT"`
+	} `envPrefix:"DB_" desc:"primary database"`
+	Upstreams []struct {
+		URL string `env:"URL,required=true"`
+	} `envPrefix:"UPSTREAM_"`
+	Labels   map[string]string `env:"LABEL_*" desc:"labels added to metrics"`
+	Password Secret[string]    `env:"PASSWORD"`
+	Ignored  string
+}
+
+func TestPrintUsage(t *testing.T) {
+	t.Parallel()
+	var b strings.Builder
+	if err := PrintUsage(&b, &UsageStruct{}); err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+
+	expected := `  PORT, HTTP_PORT int
+    	port to listen on (default 8080)
+  HOME string
+    	home directory
+    	of the service (required)
+  LABEL_* map[string]string
+    	labels added to metrics
+  PASSWORD env.Secret[string]
+
+Database: primary database
+  DB_HOST string
+    	database host (default "localhost")
+  DB_TIMEOUT time.Duration
+
+Upstreams[0]:
+  UPSTREAM_0_URL string
+    	(required)
+`
+	if b.String() != expected {
+		t.Errorf("Expected usage to be '%s' but got '%s'", expected, b.String())
+	}
+}
+
+func TestPrintUsageErrors(t *testing.T) {
+	t.Parallel()
+	var b strings.Builder
+	if err := PrintUsage(&b, "not a struct"); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("Expected error 'ErrInvalidValue' but got '%v'", err)
+	}
+
+	if err := NewDecoder(WithStrictTags()).PrintUsage(&b, &RequiredValueStruct{}); !errors.Is(err, ErrInvalidTag) {
+		t.Errorf("Expected error 'ErrInvalidTag' but got '%v'", err)
+	}
+}
@@ -0,0 +1,306 @@
+// Copyright 2018 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package env
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// durationType is the reflect.Type of time.Duration
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// ValidationError returned when the value of a field violates one of the
+// validation options of its tag.
+type ValidationError struct {
+	// Rule is the violated option, such as "min=1"
+	Rule string
+	// Value is the string representation of the offending value, or
+	// "[REDACTED]" if the field is a secret
+	Value string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("value %q violates %s", e.Value, e.Rule)
+}
+
+// validate checks v, the parsed value of a field, against the validation
+// options of envTag:
+//
+//   - min and max bound numbers, including time.Duration values, and the
+//     length of strings,
+//   - oneof lists the allowed values, separated by "|",
+//   - pattern is a regular expression the whole value must match,
+//   - notempty rejects empty strings, slices and maps.
+//
+// Values that are not strings are compared to oneof and pattern through their
+// Marshal representation. The options apply to each element of slices and to
+// each value of maps, while notempty also applies to them as a whole. Nil
+// pointers are not validated. The values of secret fields are left out of the
+// returned errors.
+func validate(c *config, v reflect.Value, envTag tag) error {
+	if envTag.Min == "" && envTag.Max == "" && len(envTag.OneOf) == 0 && envTag.Pattern == "" && !envTag.NotEmpty {
+		return nil
+	}
+
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	// Secrets are validated like the value they hold
+	if v.CanInterface() {
+		if s, ok := v.Interface().(secret); ok {
+			return validate(c, s.secretValue(), envTag)
+		}
+	}
+
+	// Types handled as a whole, such as net.IP, are not split into elements
+	if !implementsMarshaler(v.Type()) && !c.registry.hasFormatter(v.Type()) {
+		switch v.Kind() {
+		case reflect.Slice:
+			if envTag.NotEmpty && v.Len() == 0 {
+				return &ValidationError{Rule: tagKeyNotEmpty, Value: ""}
+			}
+			for i := range v.Len() {
+				if err := validate(c, v.Index(i), envTag); err != nil {
+					return fmt.Errorf("element %d: %w", i, err)
+				}
+			}
+			return nil
+		case reflect.Map:
+			if envTag.NotEmpty && v.Len() == 0 {
+				return &ValidationError{Rule: tagKeyNotEmpty, Value: ""}
+			}
+			iter := v.MapRange()
+			for iter.Next() {
+				if err := validate(c, iter.Value(), envTag); err != nil {
+					return fmt.Errorf("entry %v: %w", iter.Key(), err)
+				}
+			}
+			return nil
+		}
+	}
+
+	var value string
+	if v.Kind() == reflect.String && !implementsMarshaler(v.Type()) {
+		value = v.String()
+	} else {
+		var err error
+		if value, err = marshalValue(c, v, tag{}); err != nil {
+			return err
+		}
+	}
+
+	if envTag.NotEmpty && value == "" {
+		return &ValidationError{Rule: tagKeyNotEmpty, Value: value}
+	}
+
+	// shown is the value reported in errors
+	shown := value
+	if envTag.Secret {
+		shown = redactedValue
+	}
+	if envTag.Min != "" {
+		if err := checkBound(v, shown, tagKeyMin, envTag.Min); err != nil {
+			return err
+		}
+	}
+	if envTag.Max != "" {
+		if err := checkBound(v, shown, tagKeyMax, envTag.Max); err != nil {
+			return err
+		}
+	}
+	if len(envTag.OneOf) > 0 && !slices.Contains(envTag.OneOf, value) {
+		return &ValidationError{Rule: tagKeyOneOf + "=" + strings.Join(envTag.OneOf, "|"), Value: shown}
+	}
+	if envTag.Pattern != "" {
+		re, err := regexp.Compile("^(?:" + envTag.Pattern + ")$")
+		if err != nil {
+			return fmt.Errorf("%w: pattern: %w", ErrInvalidTag, err)
+		}
+		if !re.MatchString(value) {
+			return &ValidationError{Rule: tagKeyPattern + "=" + envTag.Pattern, Value: shown}
+		}
+	}
+	return nil
+}
+
+// checkBound checks that v satisfies the min or max option given by rule, with
+// the given bound, reporting value as the offending value otherwise.
+func checkBound(v reflect.Value, value, rule, bound string) error {
+	var (
+		cmp int
+		err error
+	)
+	switch {
+	case v.Type() == durationType:
+		var b time.Duration
+		if b, err = time.ParseDuration(bound); err == nil {
+			cmp = compare(time.Duration(v.Int()), b)
+		}
+	case v.CanInt():
+		var b int64
+		if b, err = strconv.ParseInt(bound, 10, 64); err == nil {
+			cmp = compare(v.Int(), b)
+		}
+	case v.CanUint():
+		var b uint64
+		if b, err = strconv.ParseUint(bound, 10, 64); err == nil {
+			cmp = compare(v.Uint(), b)
+		}
+	case v.CanFloat():
+		var b float64
+		if b, err = strconv.ParseFloat(bound, 64); err == nil {
+			cmp = compare(v.Float(), b)
+		}
+	case v.Kind() == reflect.String:
+		var b int
+		if b, err = strconv.Atoi(bound); err == nil {
+			cmp = compare(utf8.RuneCountInString(v.String()), b)
+		}
+	default:
+		return fmt.Errorf("%w: %s does not apply to %s", ErrInvalidTag, rule, v.Type())
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrInvalidTag, rule, err)
+	}
+
+	if (rule == tagKeyMin && cmp < 0) || (rule == tagKeyMax && cmp > 0) {
+		return &ValidationError{Rule: rule + "=" + bound, Value: value}
+	}
+	return nil
+}
+
+// compare returns -1, 0 or +1 depending on whether a is less than, equal to
+// or greater than b.
+func compare[T int | int64 | uint64 | float64 | time.Duration](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Validator is implemented by structs that check their own fields once they
+// are set, such as rules spanning several fields.
+type Validator interface {
+	// Validate returns an error if the struct is not valid.
+	Validate() error
+}
+
+// StructError is returned when a struct as a whole fails to unmarshal, such
+// as when its Validate method or one of its field groups fails. It wraps the
+// underlying cause.
+type StructError struct {
+	// Field is the dotted path of the struct from the root struct, empty for
+	// the root struct itself
+	Field string
+	// Type is the Go type of the struct
+	Type reflect.Type
+	// Err is the underlying cause
+	Err error
+}
+
+func (e *StructError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("env: struct (%s): %s", e.Type, e.Err)
+	}
+	return fmt.Sprintf("env: struct %q (%s): %s", e.Field, e.Type, e.Err)
+}
+
+// Unwrap returns the underlying cause of e.
+func (e *StructError) Unwrap() error {
+	return e.Err
+}
+
+// GroupError is returned when the fields of an "exclusive" or "together"
+// group are not set as the group requires.
+type GroupError struct {
+	// Rule is the option declaring the group, "exclusive" or "together"
+	Rule string
+	// Group is the name of the group
+	Group string
+	// Keys are the first keys of the fields of the group
+	Keys []string
+	// Set are the first keys of the fields of the group that are set
+	Set []string
+}
+
+func (e *GroupError) Error() string {
+	if e.Rule == tagKeyExclusive {
+		return fmt.Sprintf("at most one of %s can be set in exclusive group %q, got %s",
+			strings.Join(e.Keys, ", "), e.Group, strings.Join(e.Set, ", "))
+	}
+	return fmt.Sprintf("%s must be set together in group %q, got %s",
+		strings.Join(e.Keys, ", "), e.Group, strings.Join(e.Set, ", "))
+}
+
+// fieldGroup is an "exclusive" or "together" group of fields of a struct.
+type fieldGroup struct {
+	rule, name string
+	// keys and set are the first keys of the fields of the group, and of the
+	// ones that are set
+	keys, set []string
+}
+
+// fieldGroups are the groups of fields of a struct, in the order they are
+// first declared.
+type fieldGroups []*fieldGroup
+
+// add adds the field with the given tag to its groups, if any.
+func (g *fieldGroups) add(envTag tag, set bool) {
+	for _, rule := range [][2]string{{tagKeyExclusive, envTag.Exclusive}, {tagKeyTogether, envTag.Together}} {
+		if rule[1] == "" {
+			continue
+		}
+		i := slices.IndexFunc(*g, func(group *fieldGroup) bool {
+			return group.rule == rule[0] && group.name == rule[1]
+		})
+		if i < 0 {
+			i = len(*g)
+			*g = append(*g, &fieldGroup{rule: rule[0], name: rule[1]})
+		}
+		group := (*g)[i]
+		group.keys = append(group.keys, envTag.Keys[0])
+		if set {
+			group.set = append(group.set, envTag.Keys[0])
+		}
+	}
+}
+
+// check returns a *GroupError for each group whose fields are not set as the
+// group requires.
+func (g fieldGroups) check() []error {
+	var errs []error
+	for _, group := range g {
+		switch {
+		case group.rule == tagKeyExclusive && len(group.set) > 1,
+			group.rule == tagKeyTogether && len(group.set) > 0 && len(group.set) < len(group.keys):
+			errs = append(errs, &GroupError{Rule: group.rule, Group: group.name, Keys: group.keys, Set: group.set})
+		}
+	}
+	return errs
+}
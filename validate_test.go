@@ -0,0 +1,299 @@
+// Copyright 2018 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package env
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type ValidatedStruct struct {
+	Port     int               `env:"PORT,min=1,max=65535"`
+	LogLevel string            `env:"LOG_LEVEL,default=info,oneof=debug|info|warn|error"`
+	Name     string            `env:"NAME,notempty=true,max=8"`
+	Version  string            `env:"VERSION,pattern=v[0-9]+"`
+	Timeout  time.Duration     `env:"TIMEOUT,min=1s,max=1m"`
+	Ratio    *float64          `env:"RATIO,min=0,max=1"`
+	Hosts    []string          `env:"HOSTS,notempty=true,pattern=[a-z.]+"`
+	Weights  map[string]int    `env:"WEIGHT_*,min=0"`
+	Limits   map[string]uint   `env:"LIMITS,max=10"`
+	Optional string            `env:"OPTIONAL,notempty=true"`
+	Labels   map[string]string `env:"LABELS"`
+}
+
+func TestUnmarshalValidation(t *testing.T) {
+	t.Parallel()
+	var (
+		environ = map[string]string{
+			"PORT":     "8080",
+			"NAME":     "service",
+			"VERSION":  "v12",
+			"TIMEOUT":  "30s",
+			"RATIO":    "0.5",
+			"HOSTS":    "a.example|b.example",
+			"WEIGHT_a": "1",
+			"LIMITS":   "a:10",
+		}
+		validatedStruct ValidatedStruct
+	)
+
+	if err := Unmarshal(environ, &validatedStruct); err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+
+	if validatedStruct.LogLevel != "info" {
+		t.Errorf("Expected field value to be '%s' but got '%s'", "info", validatedStruct.LogLevel)
+	}
+}
+
+func TestUnmarshalValidationErrors(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		environ map[string]string
+		field   string
+		rule    string
+		value   string
+	}{
+		{map[string]string{"PORT": "0"}, "Port", "min=1", "0"},
+		{map[string]string{"PORT": "70000"}, "Port", "max=65535", "70000"},
+		{map[string]string{"LOG_LEVEL": "trace"}, "LogLevel", "oneof=debug|info|warn|error", "trace"},
+		{map[string]string{"NAME": ""}, "Name", "notempty", ""},
+		{map[string]string{"NAME": "long-service"}, "Name", "max=8", "long-service"},
+		{map[string]string{"VERSION": "v1.2"}, "Version", "pattern=v[0-9]+", "v1.2"},
+		{map[string]string{"TIMEOUT": "2m"}, "Timeout", "max=1m", "2m0s"},
+		{map[string]string{"RATIO": "1.5"}, "Ratio", "max=1", "1.5"},
+		{map[string]string{"HOSTS": "a.example|B"}, "Hosts", "pattern=[a-z.]+", "B"},
+		{map[string]string{"WEIGHT_a": "-1"}, "Weights", "min=0", "-1"},
+		{map[string]string{"LIMITS": "a:11"}, "Limits", "max=10", "11"},
+	}
+	for _, testCase := range testCases {
+		var validatedStruct ValidatedStruct
+		err := Unmarshal(testCase.environ, &validatedStruct)
+
+		var fieldErr *FieldError
+		var validationErr *ValidationError
+		if !errors.As(err, &fieldErr) || !errors.As(err, &validationErr) {
+			t.Errorf("Expected error 'ValidationError' for '%v' but got '%v'", testCase.environ, err)
+			continue
+		}
+		if fieldErr.Field != testCase.field {
+			t.Errorf("Expected field path to be '%s' but got '%s'", testCase.field, fieldErr.Field)
+		}
+		if validationErr.Rule != testCase.rule {
+			t.Errorf("Expected rule to be '%s' but got '%s'", testCase.rule, validationErr.Rule)
+		}
+		if validationErr.Value != testCase.value {
+			t.Errorf("Expected value to be '%s' but got '%s'", testCase.value, validationErr.Value)
+		}
+	}
+}
+
+type BareOptionStruct struct {
+	Name  string   `env:"NAME,required,notempty"`
+	Hosts []string `env:"HOSTS,notempty"`
+}
+
+func TestUnmarshalBareOptions(t *testing.T) {
+	t.Parallel()
+	decoder := NewDecoder(WithStrictTags())
+	testCases := []struct {
+		environ map[string]string
+		field   string
+		rule    string
+	}{
+		{map[string]string{"NAME": "", "HOSTS": "a"}, "Name", "notempty"},
+		{map[string]string{"NAME": "a", "HOSTS": ""}, "Hosts", "notempty"},
+	}
+	for _, testCase := range testCases {
+		var bareOptionStruct BareOptionStruct
+		err := decoder.Unmarshal(testCase.environ, &bareOptionStruct)
+
+		var fieldErr *FieldError
+		var validationErr *ValidationError
+		if !errors.As(err, &fieldErr) || !errors.As(err, &validationErr) {
+			t.Errorf("Expected error 'ValidationError' for '%v' but got '%v'", testCase.environ, err)
+			continue
+		}
+		if fieldErr.Field != testCase.field {
+			t.Errorf("Expected field path to be '%s' but got '%s'", testCase.field, fieldErr.Field)
+		}
+		if validationErr.Rule != testCase.rule {
+			t.Errorf("Expected rule to be '%s' but got '%s'", testCase.rule, validationErr.Rule)
+		}
+	}
+
+	var bareOptionStruct BareOptionStruct
+	err := decoder.Unmarshal(map[string]string{"HOSTS": "a"}, &bareOptionStruct)
+	if !errors.As(err, new(*ErrMissingRequiredValue)) {
+		t.Errorf("Expected error 'ErrMissingRequiredValue' but got '%v'", err)
+	}
+}
+
+func TestUnmarshalValidationAllErrors(t *testing.T) {
+	t.Parallel()
+	var (
+		environ         = map[string]string{"PORT": "0", "LOG_LEVEL": "trace", "HOSTS": ""}
+		validatedStruct ValidatedStruct
+	)
+
+	err := NewDecoder(WithAllErrors()).Unmarshal(environ, &validatedStruct)
+
+	var errs Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected error 'Errors' but got '%v'", err)
+	}
+
+	var fields []string
+	for _, err := range errs {
+		var fieldErr *FieldError
+		if errors.As(err, &fieldErr) {
+			fields = append(fields, fieldErr.Field)
+		}
+	}
+	if expected := []string{"Port", "LogLevel", "Hosts"}; !reflect.DeepEqual(fields, expected) {
+		t.Errorf("Expected failing fields to be '%v' but got '%v'", expected, fields)
+	}
+}
+
+type InvalidRuleStruct struct {
+	Enabled bool   `env:"ENABLED,min=1"`
+	Name    string `env:"NAME,pattern=("`
+	Size    int    `env:"SIZE,max=large"`
+}
+
+func TestUnmarshalInvalidValidationRule(t *testing.T) {
+	t.Parallel()
+	environ := map[string]string{"ENABLED": "true", "NAME": "name", "SIZE": "1"}
+
+	err := NewDecoder(WithAllErrors()).Unmarshal(environ, &InvalidRuleStruct{})
+
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) != 3 {
+		t.Fatalf("Expected %d errors but got '%v'", 3, err)
+	}
+	for _, err := range errs {
+		if !errors.Is(err, ErrInvalidTag) {
+			t.Errorf("Expected error 'ErrInvalidTag' but got '%s'", err)
+		}
+	}
+}
+
+type TLSConfig struct {
+	Cert string `env:"CERT,together=tls"`
+	Key  string `env:"KEY,together=tls"`
+
+	// validated, if set, records the calls to Validate
+	validated *[]string
+}
+
+func (c TLSConfig) Validate() error {
+	if c.validated != nil {
+		*c.validated = append(*c.validated, "TLS")
+	}
+	if c.Cert != "" && c.Cert == c.Key {
+		return errors.New("cert and key must differ")
+	}
+	return nil
+}
+
+type ServerConfig struct {
+	TLS      TLSConfig `envPrefix:"TLS_"`
+	Password string    `env:"PASSWORD,exclusive=auth"`
+	Token    string    `env:"TOKEN,exclusive=auth"`
+	Port     int       `env:"PORT,default=8080"`
+
+	validated []string
+}
+
+func (c *ServerConfig) Validate() error {
+	c.validated = append(c.validated, "ServerConfig")
+	if c.Port == 0 {
+		return errors.New("port must be set")
+	}
+	return nil
+}
+
+func TestUnmarshalValidator(t *testing.T) {
+	t.Parallel()
+	var (
+		environ      = map[string]string{"TLS_CERT": "cert", "TLS_KEY": "key", "TOKEN": "token"}
+		serverConfig ServerConfig
+	)
+	serverConfig.TLS.validated = &serverConfig.validated
+
+	if err := Unmarshal(environ, &serverConfig); err != nil {
+		t.Errorf("Expected no error but got '%s'", err)
+	}
+
+	// Nested structs are validated before the structs holding them
+	if !reflect.DeepEqual(serverConfig.validated, []string{"TLS", "ServerConfig"}) {
+		t.Errorf("Expected Validate to be called once per struct, innermost first, but got '%v'", serverConfig.validated)
+	}
+
+	environ = map[string]string{"TLS_CERT": "same", "TLS_KEY": "same", "PORT": "0"}
+	err := NewDecoder(WithAllErrors()).Unmarshal(environ, &ServerConfig{})
+
+	var structErr *StructError
+	if !errors.As(err, &structErr) {
+		t.Fatalf("Expected error 'StructError' but got '%v'", err)
+	}
+	if structErr.Field != "TLS" {
+		t.Errorf("Expected struct path to be '%s' but got '%s'", "TLS", structErr.Field)
+	}
+
+	// The root struct is not validated once a nested struct fails
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Errorf("Expected a single error but got '%v'", err)
+	}
+
+	err = Unmarshal(map[string]string{"PORT": "0"}, &ServerConfig{})
+	if !errors.As(err, &structErr) || structErr.Field != "" || structErr.Err.Error() != "port must be set" {
+		t.Errorf("Expected error from the root struct but got '%v'", err)
+	}
+}
+
+func TestUnmarshalFieldGroups(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		environ map[string]string
+		rule    string
+		keys    []string
+		set     []string
+	}{
+		{map[string]string{"TLS_CERT": "cert"}, tagKeyTogether, []string{"TLS_CERT", "TLS_KEY"}, []string{"TLS_CERT"}},
+		{map[string]string{"TLS_KEY": "key", "PORT": "443"}, tagKeyTogether, []string{"TLS_CERT", "TLS_KEY"}, []string{"TLS_KEY"}},
+		{map[string]string{"PASSWORD": "password", "TOKEN": "token"}, tagKeyExclusive, []string{"PASSWORD", "TOKEN"}, []string{"PASSWORD", "TOKEN"}},
+	}
+	for _, testCase := range testCases {
+		err := Unmarshal(testCase.environ, &ServerConfig{})
+
+		var groupErr *GroupError
+		if !errors.As(err, &groupErr) {
+			t.Errorf("Expected error 'GroupError' for '%v' but got '%v'", testCase.environ, err)
+			continue
+		}
+		if groupErr.Rule != testCase.rule {
+			t.Errorf("Expected rule to be '%s' but got '%s'", testCase.rule, groupErr.Rule)
+		}
+		if !reflect.DeepEqual(groupErr.Keys, testCase.keys) {
+			t.Errorf("Expected keys to be '%v' but got '%v'", testCase.keys, groupErr.Keys)
+		}
+		if !reflect.DeepEqual(groupErr.Set, testCase.set) {
+			t.Errorf("Expected set keys to be '%v' but got '%v'", testCase.set, groupErr.Set)
+		}
+	}
+}
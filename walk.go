@@ -0,0 +1,176 @@
+// Copyright 2018 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package env
+
+import (
+	"reflect"
+	"slices"
+)
+
+// descTagName is the name of the struct field tag holding the description of
+// a field, or of a nested struct.
+const descTagName = "desc"
+
+// fieldInfo describes a field read by Unmarshal, as found by walkFields.
+type fieldInfo struct {
+	// path is the dotted path of the field from the root struct
+	path string
+	// group is the dotted path of the struct declaring the field, empty for
+	// the root struct
+	group string
+	// groupDesc is the description of the struct declaring the field
+	groupDesc string
+	// typ is the Go type of the field
+	typ reflect.Type
+	// tag is the tag of the field, with the prefixes of its structs added
+	tag tag
+	// desc is the description of the field
+	desc string
+	// indexed is set for the fields of the elements of slices of structs,
+	// whose keys hold the index given to walkFields
+	indexed bool
+}
+
+// walkFields calls fn for every tagged field of v, a struct or a pointer to a
+// struct, in the order Unmarshal visits them. As only the type of v is used,
+// slices of structs are described by a single element, whose index in keys
+// and field paths is index. Slices of structs found within elements of the
+// same struct type, as in self-referencing types, are not walked again. Like
+// Unmarshal, walkFields returns a *FieldError for invalid tags and unexported
+// tagged fields. If fn returns an error, walkFields stops and returns it.
+func (c *config) walkFields(v interface{}, index string, fn func(fieldInfo) error) error {
+	t := reflect.TypeOf(v)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return ErrInvalidValue
+	}
+	w := &fieldWalker{config: c, index: index, fn: fn, walking: make(map[reflect.Type]bool)}
+	return w.walkStruct(t, "", "", "", false)
+}
+
+// fieldWalker holds the state of a single call to walkFields.
+type fieldWalker struct {
+	config *config
+	index  string
+	fn     func(fieldInfo) error
+	// walking holds the struct types being walked, to stop at
+	// self-referencing slices of structs
+	walking map[reflect.Type]bool
+}
+
+// walkStruct calls fn for every tagged field of t, a struct type found at the
+// given field path with the given description. The keys of its fields are
+// prepended with prefix. indexed is set if t is the element of a slice.
+func (w *fieldWalker) walkStruct(t reflect.Type, path, desc, prefix string, indexed bool) error {
+	if w.walking[t] {
+		return nil
+	}
+	w.walking[t] = true
+	defer delete(w.walking, t)
+
+	c := w.config
+	for i := range t.NumField() {
+		typeField := t.Field(i)
+		fieldPath := joinPath(path, typeField.Name)
+		fieldDesc := typeField.Tag.Get(descTagName)
+		// Unexported nested structs and slices of structs are skipped, and
+		// unexported tagged fields rejected, like Unmarshal and Marshal do
+		if typeField.Type.Kind() == reflect.Struct {
+			if !typeField.IsExported() {
+				continue
+			}
+			if err := w.walkStruct(typeField.Type, fieldPath, fieldDesc, prefix+typeField.Tag.Get(c.prefixTagName()), indexed); err != nil {
+				return err
+			}
+		}
+
+		if envPrefix, ok := typeField.Tag.Lookup(c.prefixTagName()); ok && isStructSlice(typeField.Type) {
+			if !typeField.IsExported() {
+				continue
+			}
+			elemType := typeField.Type.Elem()
+			if elemType.Kind() == reflect.Ptr {
+				elemType = elemType.Elem()
+			}
+			elemPath := fieldPath + "[" + w.index + "]"
+			if err := w.walkStruct(elemType, elemPath, fieldDesc, prefix+envPrefix+w.index+"_", true); err != nil {
+				return err
+			}
+			continue
+		}
+
+		tagString := typeField.Tag.Get(c.tagName())
+		if tagString == "" {
+			continue
+		}
+
+		envTag, err := parseTag(tagString)
+		envTag.addPrefix(prefix)
+		if err == nil {
+			err = c.checkTag(envTag)
+		}
+		if err == nil && !typeField.IsExported() {
+			err = ErrUnexportedField
+		}
+		if err != nil {
+			return &FieldError{Keys: envTag.Keys, Field: fieldPath, Type: typeField.Type, Err: err}
+		}
+		if isSecretType(typeField.Type) {
+			envTag.Secret = true
+		}
+
+		if err := w.fn(fieldInfo{
+			path:      fieldPath,
+			group:     path,
+			groupDesc: desc,
+			typ:       typeField.Type,
+			tag:       envTag,
+			desc:      fieldDesc,
+			indexed:   indexed,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// structFields are the tagged fields declared by a struct.
+type structFields struct {
+	// path is the dotted path of the struct, empty for the root struct
+	path string
+	// desc is the description of the struct
+	desc string
+	// fields are the fields of the struct, in the order they are declared
+	fields []fieldInfo
+}
+
+// groupFields returns the tagged fields of v, a struct or a pointer to a
+// struct, grouped by the struct declaring them. The root struct comes first,
+// followed by the nested structs in the order they are declared. Slices of
+// structs are described by their first element.
+func (c *config) groupFields(v interface{}) ([]*structFields, error) {
+	groups := []*structFields{{}}
+	err := c.walkFields(v, "0", func(f fieldInfo) error {
+		i := slices.IndexFunc(groups, func(group *structFields) bool { return group.path == f.group })
+		if i < 0 {
+			i = len(groups)
+			groups = append(groups, &structFields{path: f.group, desc: f.groupDesc})
+		}
+		groups[i].fields = append(groups[i].fields, f)
+		return nil
+	})
+	return groups, err
+}
@@ -0,0 +1,87 @@
+// Copyright 2018 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package env
+
+import (
+	"errors"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+type WalkedStruct struct {
+	Name   string `env:"NAME"`
+	hidden struct {
+		Hidden string `env:"HIDDEN"`
+	}
+	items []struct {
+		Item string `env:"ITEM"`
+	} `envPrefix:"ITEM_"`
+	Nested struct {
+		Port int `env:"PORT"`
+	} `envPrefix:"NESTED_"`
+	Upstreams []struct {
+		Host string `env:"HOST"`
+	} `envPrefix:"UPSTREAM_"`
+}
+
+func TestWalkFieldsMatchesMarshal(t *testing.T) {
+	t.Parallel()
+	var walkedStruct WalkedStruct
+	walkedStruct.Upstreams = make([]struct {
+		Host string `env:"HOST"`
+	}, 1)
+
+	var walked []string
+	err := NewDecoder().config.walkFields(&walkedStruct, "0", func(f fieldInfo) error {
+		walked = append(walked, f.tag.Keys...)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+
+	es, err := Marshal(&walkedStruct)
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+	marshaled := slices.Sorted(maps.Keys(es))
+	slices.Sort(walked)
+	if !reflect.DeepEqual(walked, marshaled) {
+		t.Errorf("Expected walked keys to be '%v' but got '%v'", marshaled, walked)
+	}
+}
+
+func TestWalkFieldsUnexportedField(t *testing.T) {
+	t.Parallel()
+	var b strings.Builder
+	errs := []error{
+		Unmarshal(EnvSet{}, &UnexportedStruct{}),
+		func() error { _, err := Marshal(&UnexportedStruct{}); return err }(),
+		PrintUsage(&b, &UnexportedStruct{}),
+		PrintDotenvExample(&b, &UnexportedStruct{}),
+		PrintMarkdown(&b, &UnexportedStruct{}),
+		func() error { _, err := JSONSchema(&UnexportedStruct{}); return err }(),
+	}
+	for _, err := range errs {
+		var fieldErr *FieldError
+		if !errors.Is(err, ErrUnexportedField) || !errors.As(err, &fieldErr) {
+			t.Errorf("Expected error 'ErrUnexportedField' but got '%v'", err)
+		} else if fieldErr.Field != "home" {
+			t.Errorf("Expected field path to be '%s' but got '%s'", "home", fieldErr.Field)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2018 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package env
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrUnrepresentable returned when a variable cannot be written in the
+// requested format, or when Marshal cannot encode a slice or a map in a form
+// Unmarshal reads back.
+var ErrUnrepresentable = errors.New("variable cannot be represented in this format")
+
+// WriteDotenv writes es to w as a dotenv file that ParseDotenv reads back,
+// with one KEY=value line per variable, sorted by key. Values are double
+// quoted unless they only hold letters, digits and "_./:@,+-=%~". If a key is
+// not made of letters, digits and underscores, or starts with a digit,
+// WriteDotenv returns an error wrapping ErrUnrepresentable.
+func WriteDotenv(w io.Writer, es EnvSet) error {
+	return writeEnvSet(w, es, func(key, value string) (string, error) {
+		return key + "=" + quoteDotenv(value), nil
+	})
+}
+
+// WriteShell writes es to w as POSIX shell commands exporting its variables,
+// which can safely be sourced or passed to eval, with one export KEY='value'
+// line per variable, sorted by key. If a key is not a valid shell variable
+// name, WriteShell returns an error wrapping ErrUnrepresentable.
+func WriteShell(w io.Writer, es EnvSet) error {
+	return writeEnvSet(w, es, func(key, value string) (string, error) {
+		return "export " + key + "='" + strings.ReplaceAll(value, "'", `'\''`) + "'", nil
+	})
+}
+
+// WriteDockerEnvFile writes es to w in the format of the --env-file option of
+// docker run, with one KEY=value line per variable, sorted by key. As Docker
+// takes values literally and does not support quoting, WriteDockerEnvFile only
+// writes values that ParseDotenv also reads back as is. It returns an error
+// wrapping ErrUnrepresentable for values holding newlines, quotes,
+// backslashes, leading or trailing whitespace, or a "#" after whitespace, and
+// for keys that are not made of letters, digits and underscores.
+func WriteDockerEnvFile(w io.Writer, es EnvSet) error {
+	return writeEnvSet(w, es, func(key, value string) (string, error) {
+		if reason := dockerUnrepresentable(value); reason != "" {
+			return "", fmt.Errorf("%w: value of %q holds %s", ErrUnrepresentable, key, reason)
+		}
+		return key + "=" + value, nil
+	})
+}
+
+// dockerUnrepresentable returns what prevents value from being written as is
+// in a Docker env file and read back by ParseDotenv, or "" if nothing does.
+func dockerUnrepresentable(value string) string {
+	switch {
+	case strings.ContainsAny(value, "\r\n"):
+		return "a newline"
+	case strings.ContainsAny(value, `'"`):
+		return "a quote"
+	case strings.Contains(value, `\`):
+		return "a backslash"
+	case strings.TrimLeft(value, " \t") != value || strings.TrimRight(value, " \t") != value:
+		return "leading or trailing whitespace"
+	case strings.Contains(value, " #") || strings.Contains(value, "\t#"):
+		return `a "#" after whitespace`
+	}
+	return ""
+}
+
+// writeEnvSet writes the variables of es to w sorted by key, one line per
+// variable, formatted by format.
+func writeEnvSet(w io.Writer, es EnvSet, format func(key, value string) (string, error)) error {
+	var b strings.Builder
+	for key, value := range es.Sorted() {
+		if !isVariableName(key) {
+			return fmt.Errorf("%w: invalid key %q", ErrUnrepresentable, key)
+		}
+		line, err := format(key, value)
+		if err != nil {
+			return err
+		}
+		b.WriteString(line + "\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// quoteDotenv returns value as is if it only holds characters that need no
+// quoting in dotenv files, and double quoted with the escapes understood by
+// ParseDotenv otherwise.
+func quoteDotenv(value string) string {
+	plain := true
+	for i := range len(value) {
+		if !isVariableChar(value[i], false) && !strings.ContainsRune("./:@,+-=%~", rune(value[i])) {
+			plain = false
+			break
+		}
+	}
+	if plain {
+		return value
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := range len(value) {
+		switch c := value[i]; c {
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '"', '\\', '$':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
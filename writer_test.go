@@ -0,0 +1,153 @@
+// Copyright 2018 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package env
+
+import (
+	"errors"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var writerEnvSet = EnvSet{
+	"PLAIN":   "http://localhost:8080/path",
+	"SPACES":  "two words",
+	"QUOTES":  `it's "quoted"`,
+	"SPECIAL": "# $HOME \\ `date`",
+	"MULTI":   "first\nsecond\r\n\tthird",
+	"EMPTY":   "",
+}
+
+func TestWriteDotenv(t *testing.T) {
+	t.Parallel()
+	var b strings.Builder
+	if err := WriteDotenv(&b, writerEnvSet); err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+
+	expected := `EMPTY=
+MULTI="first\nsecond\r\n\tthird"
+PLAIN=http://localhost:8080/path
+QUOTES="it's \"quoted\""
+SPACES="two words"
+SPECIAL="# \$HOME \\ ` + "`date`" + `"
+`
+	if b.String() != expected {
+		t.Errorf("Expected dotenv to be '%s' but got '%s'", expected, b.String())
+	}
+
+	es, err := ParseDotenv(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+	if !reflect.DeepEqual(es, writerEnvSet) {
+		t.Errorf("Expected EnvSet to be '%v' but got '%v'", writerEnvSet, es)
+	}
+}
+
+func TestWriteShell(t *testing.T) {
+	t.Parallel()
+	var b strings.Builder
+	if err := WriteShell(&b, writerEnvSet); err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+
+	if !strings.Contains(b.String(), `export QUOTES='it'\''s "quoted"'`+"\n") {
+		t.Errorf("Expected quotes to be escaped but got '%s'", b.String())
+	}
+
+	es, err := ParseDotenv(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+	if !reflect.DeepEqual(es, writerEnvSet) {
+		t.Errorf("Expected EnvSet to be '%v' but got '%v'", writerEnvSet, es)
+	}
+
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh is not available")
+	}
+	out, err := exec.Command(sh, "-c", b.String()+`printf '%s' "$SPECIAL|$QUOTES|$MULTI"`).Output()
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+	if expected := writerEnvSet["SPECIAL"] + "|" + writerEnvSet["QUOTES"] + "|" + writerEnvSet["MULTI"]; string(out) != expected {
+		t.Errorf("Expected shell values to be '%q' but got '%q'", expected, out)
+	}
+}
+
+func TestWriteDockerEnvFile(t *testing.T) {
+	t.Parallel()
+	var b strings.Builder
+	es := EnvSet{"B": "a=b#c $HOME", "A": "two words", "C": ""}
+	if err := WriteDockerEnvFile(&b, es); err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+
+	if expected := "A=two words\nB=a=b#c $HOME\nC=\n"; b.String() != expected {
+		t.Errorf("Expected env file to be '%s' but got '%s'", expected, b.String())
+	}
+
+	parsed, err := ParseDotenv(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatalf("Expected no error but got '%s'", err)
+	}
+	if !reflect.DeepEqual(parsed, es) {
+		t.Errorf("Expected parsed env file to be '%v' but got '%v'", es, parsed)
+	}
+
+	values := []string{"a\nb", `it's`, `say "hi"`, `C:\dir`, " leading", "trailing\t", "value # comment", "value\t#comment"}
+	for _, value := range values {
+		if err := WriteDockerEnvFile(&b, EnvSet{"A": value}); !errors.Is(err, ErrUnrepresentable) {
+			t.Errorf("Expected error 'ErrUnrepresentable' for '%q' but got '%v'", value, err)
+		}
+	}
+}
+
+func TestWriteInvalidKey(t *testing.T) {
+	t.Parallel()
+	es := EnvSet{"NOT VALID": "value"}
+	writers := []func(w *strings.Builder) error{
+		func(w *strings.Builder) error { return WriteDotenv(w, es) },
+		func(w *strings.Builder) error { return WriteShell(w, es) },
+		func(w *strings.Builder) error { return WriteDockerEnvFile(w, es) },
+	}
+	for _, write := range writers {
+		var b strings.Builder
+		if err := write(&b); !errors.Is(err, ErrUnrepresentable) {
+			t.Errorf("Expected error 'ErrUnrepresentable' but got '%v'", err)
+		}
+		if b.Len() != 0 {
+			t.Errorf("Expected nothing to be written but got '%s'", b.String())
+		}
+	}
+}
+
+func TestQuoteDotenv(t *testing.T) {
+	t.Parallel()
+	testCases := [][]string{
+		{quoteDotenv("http://localhost:8080/path"), "http://localhost:8080/path"},
+		{quoteDotenv("two words"), `"two words"`},
+		{quoteDotenv(`say "hi"`), `"say \"hi\""`},
+		{quoteDotenv("# not a comment"), `"# not a comment"`},
+		{quoteDotenv("$HOME"), `"\$HOME"`},
+	}
+	for _, testCase := range testCases {
+		if testCase[0] != testCase[1] {
+			t.Errorf("Expected '%s' but got '%s'", testCase[1], testCase[0])
+		}
+	}
+}